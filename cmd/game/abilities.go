@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+
+	"github.com/sky0621/koro/internal/koro"
+	"github.com/sky0621/koro/internal/render"
+)
+
+// pulseFlashFrames is how long the pulse ring stays on screen after a pulse.
+const pulseFlashFrames = 12
+
+var (
+	colorDash      = color.RGBA{255, 255, 160, 255}
+	colorPulse     = color.RGBA{120, 200, 255, 255}
+	colorPulseRing = color.RGBA{60, 100, 128, 96}
+)
+
+type abilityInfo struct {
+	ability koro.Ability
+	label   string
+	color   color.Color
+}
+
+var abilityInfos = []abilityInfo{
+	{koro.AbilityDash, "Dash", colorDash},
+	{koro.AbilityPulse, "Pulse", colorPulse},
+}
+
+// abilityStatus shows each enabled ability as ready or with its cooldown.
+func (g *Game) abilityStatus() string {
+	var text string
+	for _, info := range abilityInfos {
+		if !g.player.Abilities().Has(info.ability) {
+			continue
+		}
+		if text == "" {
+			text = "\n"
+		}
+		left, _ := g.player.Cooldown(info.ability)
+		if left == 0 {
+			text += fmt.Sprintf("%s ready  ", info.label)
+		} else {
+			text += fmt.Sprintf("%s %ds  ", info.label, (left+59)/60)
+		}
+	}
+	return text
+}
+
+// drawAbilityBars draws a recharge bar per enabled ability along the bottom
+// edge and the ring of a pulse that just went off.
+func (g *Game) drawAbilityBars(screen *ebiten.Image) {
+	const width, height = 40.0, 4.0
+	if left, total := g.player.Cooldown(koro.AbilityPulse); left > total-pulseFlashFrames {
+		cx, cy := g.player.PixelCenter()
+		v := g.camera.view()
+		render.FillCircle(screen, cx-v.x, cy-v.y, g.tileSize*1.5, colorPulseRing)
+	}
+	_, h := g.camera.size()
+	x := 2.0
+	for _, info := range abilityInfos {
+		if !g.player.Abilities().Has(info.ability) {
+			continue
+		}
+		left, total := g.player.Cooldown(info.ability)
+		filled := width * (1 - float64(left)/float64(total))
+		render.FillRect(screen, x, float64(h)-height-2, filled, height, info.color)
+		x += width + 4
+	}
+}
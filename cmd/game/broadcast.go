@@ -0,0 +1,95 @@
+package main
+
+import (
+	"github.com/sky0621/koro/internal/broadcast"
+	"github.com/sky0621/koro/internal/ghost"
+	"github.com/sky0621/koro/internal/koro"
+	"github.com/sky0621/koro/internal/level"
+)
+
+// broadcaster streams the running game to spectators, sending pellet changes
+// as deltas against the last grid it published.
+type broadcaster struct {
+	server  *broadcast.Server
+	level   *level.Level
+	pellets [][]level.PelletType
+	// revision is the level's tile revision at the last keyframe; doors and
+	// gates changing force a new one.
+	revision uint64
+}
+
+func newBroadcaster(server *broadcast.Server) *broadcaster {
+	return &broadcaster{server: server}
+}
+
+func (g *Game) publishFrame() {
+	b := g.broadcast
+	if b.server.Spectators() == 0 {
+		b.level = nil
+		return
+	}
+
+	f := &broadcast.Frame{
+		Frame:      g.frame,
+		Score:      g.score,
+		Lives:      g.lives,
+		Level:      g.levelNumber,
+		State:      int(g.state),
+		PowerTimer: g.powerTimer,
+		Player:     actorFor(g.player, false),
+		Ghosts:     make([]broadcast.Actor, len(g.ghosts)),
+	}
+	for i, gh := range g.ghosts {
+		f.Ghosts[i] = ghostActor(gh)
+	}
+
+	lvl := g.level
+	if b.level != lvl || b.revision != lvl.Revision() || b.server.NeedsKeyframe() {
+		b.level = lvl
+		b.revision = lvl.Revision()
+		b.pellets = make([][]level.PelletType, lvl.Height)
+		k := &broadcast.Keyframe{
+			Layout:   lvl.StateLayout(),
+			TileSize: lvl.TileSize,
+			Pellets:  make([]level.PelletType, 0, lvl.Width*lvl.Height),
+		}
+		for row := 0; row < lvl.Height; row++ {
+			b.pellets[row] = make([]level.PelletType, lvl.Width)
+			for col := 0; col < lvl.Width; col++ {
+				p := lvl.PelletAt(col, row)
+				b.pellets[row][col] = p
+				k.Pellets = append(k.Pellets, p)
+			}
+		}
+		f.Keyframe = k
+	} else {
+		for row := 0; row < lvl.Height; row++ {
+			for col := 0; col < lvl.Width; col++ {
+				p := lvl.PelletAt(col, row)
+				if p == b.pellets[row][col] {
+					continue
+				}
+				b.pellets[row][col] = p
+				f.Changes = append(f.Changes, broadcast.PelletChange{
+					Pos:    level.GridPos{Col: col, Row: row},
+					Pellet: p,
+				})
+			}
+		}
+	}
+	b.server.Publish(f)
+}
+
+func actorFor(k *koro.Koro, frightened bool) broadcast.Actor {
+	x, y := k.Pixels()
+	return broadcast.Actor{
+		X:          float32(x),
+		Y:          float32(y),
+		Dir:        uint8(k.Direction()),
+		Frightened: frightened,
+	}
+}
+
+func ghostActor(gh *ghost.Ghost) broadcast.Actor {
+	return actorFor(gh.Body(), gh.IsFrightened())
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"math"
+
+	"github.com/sky0621/koro/internal/koro"
+	"github.com/sky0621/koro/internal/level"
+	"github.com/sky0621/koro/internal/netplay"
+)
+
+const (
+	// viewportMaxCols and viewportMaxRows bound the screen; larger levels
+	// scroll.
+	viewportMaxCols = 28
+	viewportMaxRows = 31
+	// cameraDeadZone is the share of the view, centred, the focus may roam
+	// without moving the camera.
+	cameraDeadZone = 0.3
+	// cameraLookAhead is how many tiles the camera leads the player by.
+	cameraLookAhead = 3
+	cameraSmoothing = 0.12
+	// cameraJumpTiles is the per-frame move that counts as a warp, not walking.
+	cameraJumpTiles = 2
+)
+
+// view is the part of the world drawn on screen, in world pixels.
+type view struct {
+	x, y, w, h float64
+}
+
+// fullView shows the whole level.
+func fullView(lvl *level.Level) view {
+	return view{w: float64(lvl.PixelWidth()), h: float64(lvl.PixelHeight())}
+}
+
+// tiles returns the half-open range of tiles the view overlaps, clamped to the level.
+func (v view) tiles(lvl *level.Level) (col0, row0, col1, row1 int) {
+	size := float64(lvl.TileSize)
+	col0 = max(int(math.Floor(v.x/size)), 0)
+	row0 = max(int(math.Floor(v.y/size)), 0)
+	col1 = min(int(math.Ceil((v.x+v.w)/size)), lvl.Width)
+	row1 = min(int(math.Ceil((v.y+v.h)/size)), lvl.Height)
+	return col0, row0, col1, row1
+}
+
+// camera follows one actor across levels larger than the screen. It is
+// presentation only, so it is not part of snapshots or saves.
+type camera struct {
+	x, y         float64
+	w, h         float64
+	lookX, lookY float64
+	lastX, lastY float64
+	ready        bool
+	// whole keeps the entire level in view, for players sharing one screen:
+	// following either of them could leave the other off it.
+	whole bool
+}
+
+// viewportSize is the screen size for a level: all of it, up to the maximum.
+func viewportSize(lvl *level.Level) (int, int) {
+	return min(lvl.PixelWidth(), viewportMaxCols*lvl.TileSize), min(lvl.PixelHeight(), viewportMaxRows*lvl.TileSize)
+}
+
+func newCamera(lvl *level.Level, whole bool) *camera {
+	w, h := viewportSize(lvl)
+	if whole {
+		w, h = lvl.PixelWidth(), lvl.PixelHeight()
+	}
+	return &camera{w: float64(w), h: float64(h), whole: whole}
+}
+
+// size returns the screen size the camera draws to.
+func (c *camera) size() (int, int) {
+	return int(c.w), int(c.h)
+}
+
+// follow moves the view towards the focus: the centre of the actor plus a
+// smoothed lead in its heading. The camera only moves once the focus leaves
+// the dead zone, and a warp shifts the view by the jump instead of panning.
+func (c *camera) follow(lvl *level.Level, k *koro.Koro) {
+	if c.whole {
+		return
+	}
+	tile := float64(lvl.TileSize)
+	cx, cy := k.PixelCenter()
+	switch {
+	case !c.ready:
+		c.x, c.y = cx-c.w/2, cy-c.h/2
+		c.ready = true
+	case math.Abs(cx-c.lastX) > cameraJumpTiles*tile || math.Abs(cy-c.lastY) > cameraJumpTiles*tile:
+		c.x += cx - c.lastX
+		c.y += cy - c.lastY
+	}
+	c.lastX, c.lastY = cx, cy
+
+	dx, dy := k.Direction().Delta()
+	c.lookX += (float64(dx)*cameraLookAhead*tile - c.lookX) * cameraSmoothing
+	c.lookY += (float64(dy)*cameraLookAhead*tile - c.lookY) * cameraSmoothing
+
+	c.x += (deadZoneTarget(c.x, c.w, cx+c.lookX) - c.x) * cameraSmoothing
+	c.y += (deadZoneTarget(c.y, c.h, cy+c.lookY) - c.y) * cameraSmoothing
+	c.x = math.Max(0, math.Min(c.x, float64(lvl.PixelWidth())-c.w))
+	c.y = math.Max(0, math.Min(c.y, float64(lvl.PixelHeight())-c.h))
+}
+
+// deadZoneTarget returns where the view's edge at pos (spanning size) should
+// be so that focus sits just inside the dead zone on one axis.
+func deadZoneTarget(pos, size, focus float64) float64 {
+	half := size * cameraDeadZone / 2
+	mid := pos + size/2
+	switch {
+	case focus < mid-half:
+		return focus + half - size/2
+	case focus > mid+half:
+		return focus - half - size/2
+	default:
+		return pos
+	}
+}
+
+// view returns the current window, snapped to whole pixels so tiles do not shimmer.
+func (c *camera) view() view {
+	return view{x: math.Round(c.x), y: math.Round(c.y), w: c.w, h: c.h}
+}
+
+// cameraTarget is the actor controlled at this screen: the rival ghost for an
+// online guest, Koro otherwise.
+func (g *Game) cameraTarget() *koro.Koro {
+	if g.net != nil && g.net.session.Role() == netplay.RoleGuest {
+		return g.rival().Body()
+	}
+	return g.player
+}
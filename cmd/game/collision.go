@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/sky0621/koro/internal/event"
+	"github.com/sky0621/koro/internal/fixed"
+	"github.com/sky0621/koro/internal/ghost"
+	"github.com/sky0621/koro/internal/koro"
+	"github.com/sky0621/koro/internal/level"
+)
+
+// collisionMode selects how contact between Koro and a ghost is detected.
+type collisionMode int
+
+const (
+	// collisionSwept tests the closest approach of the two centres over the
+	// whole frame, so actors moving towards each other cannot slip past.
+	collisionSwept collisionMode = iota
+	// collisionTile is the arcade rule: contact means sharing a tile, or
+	// swapping tiles during the frame.
+	collisionTile
+)
+
+func parseCollisionMode(name string) (collisionMode, error) {
+	switch name {
+	case "swept":
+		return collisionSwept, nil
+	case "tile":
+		return collisionTile, nil
+	default:
+		return collisionSwept, fmt.Errorf("unknown collision mode %q", name)
+	}
+}
+
+// point is a position in tiles.
+type point struct {
+	x, y fixed.Num
+}
+
+// placement is where an actor stood at the start of a frame.
+type placement struct {
+	center point
+	tile   level.GridPos
+}
+
+func placementOf(k *koro.Koro) placement {
+	x, y := k.Center()
+	return placement{center: point{x, y}, tile: k.Tile()}
+}
+
+// framePlacements records Koro followed by every ghost before anyone moves.
+func (g *Game) framePlacements() []placement {
+	placed := make([]placement, 0, len(g.ghosts)+1)
+	placed = append(placed, placementOf(g.player))
+	for _, gh := range g.ghosts {
+		placed = append(placed, placementOf(gh.Body()))
+	}
+	return placed
+}
+
+// touching reports whether Koro met ghost i during the frame that started at placed.
+func (g *Game) touching(placed []placement, i int) bool {
+	from, ghostFrom := placed[0], placed[i+1]
+	body := g.ghosts[i].Body()
+	if g.collision == collisionTile {
+		at, ghostAt := g.player.Tile(), body.Tile()
+		return at == ghostAt || (from.tile == ghostAt && ghostFrom.tile == at)
+	}
+	px, py := g.player.Center()
+	gx, gy := body.Center()
+	return closestApproach(from.center, point{px, py}, ghostFrom.center, point{gx, gy}) <= collisionShrinkage.Mul(collisionShrinkage)
+}
+
+// closestApproach returns the smallest squared distance between two actors
+// moving in straight lines from a0 to a1 and b0 to b1 over the same frame.
+// An actor that jumped more than a tile went through a warp and is only
+// checked where it landed.
+func closestApproach(a0, a1, b0, b1 point) fixed.Num {
+	if warped(a0, a1) {
+		a0 = a1
+	}
+	if warped(b0, b1) {
+		b0 = b1
+	}
+	// Work in b's frame of reference: a starts at d and moves by v.
+	d := point{a0.x - b0.x, a0.y - b0.y}
+	v := point{a1.x - b1.x - d.x, a1.y - b1.y - d.y}
+	along := -(d.x.Mul(v.x) + d.y.Mul(v.y))
+	length := v.x.Mul(v.x) + v.y.Mul(v.y)
+	if along > 0 && length > 0 {
+		t := min(along.Div(length), fixed.One)
+		d.x += v.x.Mul(t)
+		d.y += v.y.Mul(t)
+	}
+	return d.x.Mul(d.x) + d.y.Mul(d.y)
+}
+
+func warped(from, to point) bool {
+	return (to.x-from.x).Abs() > fixed.One || (to.y-from.y).Abs() > fixed.One
+}
+
+// checkGhostCollisions resolves every ghost Koro touched this frame.
+//
+// Precedence: each frame Koro moves, then eats pellets and triggers features
+// and hazards, then the ghosts move, and only then are collisions checked. A
+// ghost touched on the frame a power pellet is eaten is therefore already
+// frightened and gets eaten.
+// Frightened ghosts are eaten before dangerous ones are dealt with, so a
+// frame touching both scores the catches and then costs a life. A shield
+// sends away every dangerous ghost touched on the frame it breaks.
+func (g *Game) checkGhostCollisions(placed []placement) {
+	var dangerous []*ghost.Ghost
+	for i, gh := range g.ghosts {
+		if !g.touching(placed, i) {
+			continue
+		}
+		switch {
+		case gh.IsFrightened():
+			pos := gh.Body().Tile()
+			g.emit(event.GhostEaten{Ghost: i, Pos: pos, Points: g.award(ghostScore)})
+			g.respawnGhostRandom(gh)
+		case gh.IsStunned():
+		default:
+			dangerous = append(dangerous, gh)
+		}
+	}
+	if len(dangerous) == 0 {
+		return
+	}
+	if g.mods().shield {
+		g.effects[effectShield] = 0
+		g.emit(event.ShieldBroken{})
+		for _, gh := range dangerous {
+			g.respawnGhostRandom(gh)
+		}
+		return
+	}
+	g.loseLife()
+}
@@ -0,0 +1,507 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+
+	"github.com/sky0621/koro/internal/koro"
+	"github.com/sky0621/koro/internal/level"
+	"github.com/sky0621/koro/internal/render"
+)
+
+const (
+	editorHUDHeight = 64
+	editorMinWidth  = 320
+	editorUndoLimit = 200
+	editorNewWidth  = 19
+	editorNewHeight = 15
+	editorMinSize   = 3
+	editorMaxSize   = 64
+)
+
+var (
+	colorIssueError   = color.RGBA{255, 60, 60, 255}
+	colorIssueWarning = color.RGBA{255, 220, 0, 255}
+	colorCursor       = color.RGBA{255, 255, 255, 160}
+	colorFruit        = color.RGBA{220, 20, 60, 255}
+)
+
+type editorBrush struct {
+	rune byte
+	name string
+}
+
+// editorBrushes lists every rune the editor paints; keys 1-9 pick the first nine.
+var editorBrushes = []editorBrush{
+	{'#', "wall"},
+	{' ', "path"},
+	{'.', "pellet"},
+	{'o', "power pellet"},
+	{'W', "warp"},
+	{'P', "player spawn"},
+	{'G', "ghost spawn"},
+	{'F', "fruit spawn"},
+	{'=', "tunnel"},
+	{'~', "mud"},
+	{':', "ice"},
+	{',', "poison"},
+	{'<', "conveyor left"},
+	{'>', "conveyor right"},
+	{'^', "conveyor up"},
+	{'v', "conveyor down"},
+	{'1', "warp 1"},
+	{'2', "warp 2"},
+	{'3', "warp 3"},
+	{'!', "speed pellet"},
+	{'*', "freeze pellet"},
+	{'?', "magnet pellet"},
+	{'$', "multiplier pellet"},
+	{'@', "shield pellet"},
+	{'A', "door A"},
+	{'a', "key a"},
+	{'B', "door B"},
+	{'b', "key b"},
+	{'+', "switch"},
+	{'%', "gate (closed)"},
+	{'_', "gate (open)"},
+}
+
+var brushKeys = [...]ebiten.Key{
+	ebiten.Key1, ebiten.Key2, ebiten.Key3, ebiten.Key4, ebiten.Key5,
+	ebiten.Key6, ebiten.Key7, ebiten.Key8, ebiten.Key9,
+}
+
+var conveyorFlows = map[byte]level.GridPos{
+	'<': {Col: -1},
+	'>': {Col: 1},
+	'^': {Row: -1},
+	'v': {Row: 1},
+}
+
+// Editor paints layouts in the text format level.New reads, validates them
+// as they change and play-tests them without leaving the window.
+type Editor struct {
+	path     string
+	rows     [][]byte
+	meta     []string
+	tileSize int
+	brush    int
+	undo     [][]string
+	redo     [][]string
+	stroking bool
+	issues   []level.Issue
+	message  string
+	playtest *Game
+}
+
+// newEditor opens the layout at path, or starts a blank one if it does not exist yet.
+func newEditor(path string) (*Editor, error) {
+	e := &Editor{path: path, tileSize: level.DefaultTileSize}
+	if level.IsTiledFile(path) {
+		// Saving must not clobber the Tiled map, so edits go to a text layout beside it.
+		m, err := level.ImportTiled(path)
+		if err != nil {
+			return nil, err
+		}
+		e.path = strings.TrimSuffix(path, filepath.Ext(path)) + ".txt"
+		e.message = "Imported; saves to " + e.path
+		e.load(m.Layout)
+		return e, nil
+	}
+	layout, err := level.ReadLayoutFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		layout = blankLayout(editorNewWidth, editorNewHeight)
+		e.message = "New file " + path
+	case err != nil:
+		return nil, err
+	}
+	e.load(layout)
+	return e, nil
+}
+
+// blankLayout is a walled room full of pellets with the player in the middle.
+func blankLayout(width, height int) []string {
+	rows := make([]string, height)
+	for row := range rows {
+		line := []byte(strings.Repeat(".", width))
+		for col := range line {
+			if row == 0 || col == 0 || row == height-1 || col == width-1 {
+				line[col] = '#'
+			}
+		}
+		if row == height/2 {
+			line[width/2] = 'P'
+		}
+		rows[row] = string(line)
+	}
+	return rows
+}
+
+// load replaces the grid, keeping anything after the metadata separator verbatim.
+// Ragged rows are padded with wall so the grid stays rectangular.
+func (e *Editor) load(layout []string) {
+	grid := layout
+	e.meta = nil
+	for i, line := range layout {
+		if line == level.MetadataSeparator {
+			grid, e.meta = layout[:i], append([]string(nil), layout[i+1:]...)
+			break
+		}
+	}
+	width := 0
+	for _, line := range grid {
+		width = max(width, len(line))
+	}
+	e.rows = make([][]byte, len(grid))
+	for i, line := range grid {
+		e.rows[i] = []byte(line + strings.Repeat("#", width-len(line)))
+	}
+	e.issues = level.Validate(e.layout())
+}
+
+func (e *Editor) layout() []string {
+	out := make([]string, 0, len(e.rows)+len(e.meta)+1)
+	for _, row := range e.rows {
+		out = append(out, string(row))
+	}
+	if len(e.meta) > 0 {
+		out = append(out, level.MetadataSeparator)
+		out = append(out, e.meta...)
+	}
+	return out
+}
+
+func (e *Editor) width() int {
+	if len(e.rows) == 0 {
+		return 0
+	}
+	return len(e.rows[0])
+}
+
+// checkpoint records the current layout for undo and forgets the redo history.
+func (e *Editor) checkpoint() {
+	e.undo = append(e.undo, e.layout())
+	if len(e.undo) > editorUndoLimit {
+		e.undo = e.undo[1:]
+	}
+	e.redo = nil
+}
+
+func (e *Editor) changed() {
+	e.message = ""
+	e.issues = level.Validate(e.layout())
+}
+
+func (e *Editor) undoEdit() {
+	if len(e.undo) == 0 {
+		return
+	}
+	e.redo = append(e.redo, e.layout())
+	e.message = ""
+	e.load(e.undo[len(e.undo)-1])
+	e.undo = e.undo[:len(e.undo)-1]
+}
+
+func (e *Editor) redoEdit() {
+	if len(e.redo) == 0 {
+		return
+	}
+	e.undo = append(e.undo, e.layout())
+	e.message = ""
+	e.load(e.redo[len(e.redo)-1])
+	e.redo = e.redo[:len(e.redo)-1]
+}
+
+// paint sets one tile, taking the undo checkpoint on the first change of a
+// stroke. A layout holds one player spawn, so painting one moves it.
+func (e *Editor) paint(pos level.GridPos, ch byte) {
+	if pos.Row < 0 || pos.Row >= len(e.rows) || pos.Col < 0 || pos.Col >= e.width() {
+		return
+	}
+	if e.rows[pos.Row][pos.Col] == ch {
+		return
+	}
+	if !e.stroking {
+		e.checkpoint()
+		e.stroking = true
+	}
+	if ch == 'P' {
+		for _, row := range e.rows {
+			for col := range row {
+				if row[col] == 'P' {
+					row[col] = ' '
+				}
+			}
+		}
+	}
+	e.rows[pos.Row][pos.Col] = ch
+	e.changed()
+}
+
+// resize grows or shrinks the grid at its right and bottom edges; new tiles are wall.
+func (e *Editor) resize(dw, dh int) {
+	width, height := e.width()+dw, len(e.rows)+dh
+	if width < editorMinSize || height < editorMinSize || width > editorMaxSize || height > editorMaxSize {
+		return
+	}
+	e.checkpoint()
+	rows := make([][]byte, height)
+	for i := range rows {
+		rows[i] = []byte(strings.Repeat("#", width))
+		if i < len(e.rows) {
+			copy(rows[i], e.rows[i])
+		}
+	}
+	e.rows = rows
+	e.changed()
+}
+
+func (e *Editor) save() {
+	data := strings.Join(e.layout(), "\n") + "\n"
+	if err := os.WriteFile(e.path, []byte(data), 0o644); err != nil {
+		e.message = fmt.Sprintf("Save failed: %v", err)
+		return
+	}
+	e.message = "Saved " + e.path
+	if n := e.count(level.SeverityError); n > 0 {
+		e.message += fmt.Sprintf(" (%d errors)", n)
+	}
+}
+
+func (e *Editor) startPlaytest() {
+	lvl, err := level.New(e.layout(), e.tileSize)
+	if err != nil {
+		e.message = fmt.Sprintf("Cannot play: %v", err)
+		return
+	}
+	e.playtest = newGame(ModeClassic, time.Now().UnixNano(), lvl)
+	e.message = ""
+}
+
+func (e *Editor) count(severity level.Severity) int {
+	n := 0
+	for _, issue := range e.issues {
+		if issue.Severity == severity {
+			n++
+		}
+	}
+	return n
+}
+
+func (e *Editor) Update() error {
+	if e.playtest != nil {
+		if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+			e.playtest = nil
+			return nil
+		}
+		return e.playtest.Update()
+	}
+
+	ctrl := ebiten.IsKeyPressed(ebiten.KeyControl) || ebiten.IsKeyPressed(ebiten.KeyMeta)
+	shift := ebiten.IsKeyPressed(ebiten.KeyShift)
+	switch {
+	case ctrl && inpututil.IsKeyJustPressed(ebiten.KeyZ) && shift,
+		ctrl && inpututil.IsKeyJustPressed(ebiten.KeyY):
+		e.redoEdit()
+	case ctrl && inpututil.IsKeyJustPressed(ebiten.KeyZ):
+		e.undoEdit()
+	case ctrl && inpututil.IsKeyJustPressed(ebiten.KeyS):
+		e.save()
+	case inpututil.IsKeyJustPressed(ebiten.KeyF5), inpututil.IsKeyJustPressed(ebiten.KeyEnter):
+		e.startPlaytest()
+		return nil
+	case shift && inpututil.IsKeyJustPressed(ebiten.KeyArrowRight):
+		e.resize(1, 0)
+	case shift && inpututil.IsKeyJustPressed(ebiten.KeyArrowLeft):
+		e.resize(-1, 0)
+	case shift && inpututil.IsKeyJustPressed(ebiten.KeyArrowDown):
+		e.resize(0, 1)
+	case shift && inpututil.IsKeyJustPressed(ebiten.KeyArrowUp):
+		e.resize(0, -1)
+	}
+	e.updateBrush(shift)
+	e.updatePainting()
+	return nil
+}
+
+func (e *Editor) updateBrush(shift bool) {
+	for i, key := range brushKeys {
+		if i < len(editorBrushes) && inpututil.IsKeyJustPressed(key) {
+			e.brush = i
+		}
+	}
+	step := 0
+	if inpututil.IsKeyJustPressed(ebiten.KeyTab) {
+		step = 1
+		if shift {
+			step = -1
+		}
+	}
+	if _, wy := ebiten.Wheel(); wy > 0 {
+		step = -1
+	} else if wy < 0 {
+		step = 1
+	}
+	e.brush = (e.brush + step + len(editorBrushes)) % len(editorBrushes)
+}
+
+// updatePainting paints under the left mouse button and every touch, and
+// erases to plain path under the right button.
+func (e *Editor) updatePainting() {
+	painting := false
+	if ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+		e.paint(e.tileAt(ebiten.CursorPosition()), editorBrushes[e.brush].rune)
+		painting = true
+	}
+	if ebiten.IsMouseButtonPressed(ebiten.MouseButtonRight) {
+		e.paint(e.tileAt(ebiten.CursorPosition()), ' ')
+		painting = true
+	}
+	for _, id := range ebiten.AppendTouchIDs(nil) {
+		e.paint(e.tileAt(ebiten.TouchPosition(id)), editorBrushes[e.brush].rune)
+		painting = true
+	}
+	if !painting {
+		e.stroking = false
+	}
+}
+
+func (e *Editor) tileAt(x, y int) level.GridPos {
+	if x < 0 || y < 0 {
+		return level.GridPos{Col: -1, Row: -1}
+	}
+	return level.GridPos{Col: x / e.tileSize, Row: y / e.tileSize}
+}
+
+func (e *Editor) Draw(screen *ebiten.Image) {
+	if e.playtest != nil {
+		e.playtest.Draw(screen)
+		return
+	}
+
+	size := float64(e.tileSize)
+	for row, line := range e.rows {
+		for col, ch := range line {
+			drawEditorTile(screen, float64(col)*size, float64(row)*size, size, ch)
+		}
+	}
+	for _, issue := range e.issues {
+		if issue.Row < 0 || issue.Row >= len(e.rows) || issue.Col >= e.width() {
+			continue
+		}
+		clr := colorIssueWarning
+		if issue.Severity == level.SeverityError {
+			clr = colorIssueError
+		}
+		strokeTile(screen, float64(issue.Col)*size, float64(issue.Row)*size, size, clr)
+	}
+	if pos := e.tileAt(ebiten.CursorPosition()); pos.Row < len(e.rows) && pos.Col < e.width() {
+		strokeTile(screen, float64(pos.Col)*size, float64(pos.Row)*size, size, colorCursor)
+	}
+	e.drawHUD(screen)
+}
+
+// drawEditorTile renders one layout rune the way the game will show it.
+func drawEditorTile(screen *ebiten.Image, x, y, size float64, ch byte) {
+	c := color.Color(colorFloor)
+	switch ch {
+	case '#':
+		c = colorWall
+	case 'W', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		c = colorWarp
+	case '=':
+		c = colorTunnel
+	case '~':
+		c = colorMud
+	case ':':
+		c = colorIce
+	case ',':
+		c = colorPoison
+	case '<', '>', '^', 'v':
+		c = colorConveyor
+	}
+	render.FillRect(screen, x, y, size, size, c)
+
+	half := size / 2
+	switch ch {
+	case '.':
+		render.FillCircle(screen, x+half, y+half, size*0.1, color.White)
+	case 'o':
+		render.FillCircle(screen, x+half, y+half, size*0.25, colorPowerPellet)
+	case '!':
+		drawPellet(screen, x+half, y+half, size, level.PelletSpeed)
+	case '*':
+		drawPellet(screen, x+half, y+half, size, level.PelletFreeze)
+	case '?':
+		drawPellet(screen, x+half, y+half, size, level.PelletMagnet)
+	case '$':
+		drawPellet(screen, x+half, y+half, size, level.PelletMultiplier)
+	case '@':
+		drawPellet(screen, x+half, y+half, size, level.PelletShield)
+	case 'P':
+		render.DrawPlayer(screen, x, y, size, koro.DirRight, colorPlayer, colorFloor)
+	case 'G':
+		render.DrawGhost(screen, x, y, size, ghostColors[0], false)
+	case 'F':
+		render.FillCircle(screen, x+half, y+half, size*0.3, colorFruit)
+	case '<', '>', '^', 'v':
+		drawConveyorArrow(screen, x+half, y+half, size, conveyorFlows[ch])
+	case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		ebitenutil.DebugPrintAt(screen, string(ch), int(x)+5, int(y))
+	case 'A', 'B', 'C', 'D', 'E':
+		drawFeature(screen, x, y, size, level.Feature{Kind: level.FeatureDoor, Key: ch - 'A' + 'a'})
+	case 'a', 'b', 'c', 'd', 'e':
+		drawFeature(screen, x, y, size, level.Feature{Kind: level.FeatureKey, Key: ch})
+	case '+':
+		drawFeature(screen, x, y, size, level.Feature{Kind: level.FeatureSwitch})
+	case '%':
+		drawFeature(screen, x, y, size, level.Feature{Kind: level.FeatureGate})
+	case '_':
+		drawFeature(screen, x, y, size, level.Feature{Kind: level.FeatureGate, Open: true})
+	}
+}
+
+func strokeTile(screen *ebiten.Image, x, y, size float64, clr color.Color) {
+	render.StrokeLine(screen, x, y, x+size, y, 1, clr)
+	render.StrokeLine(screen, x+size, y, x+size, y+size, 1, clr)
+	render.StrokeLine(screen, x+size, y+size, x, y+size, 1, clr)
+	render.StrokeLine(screen, x, y+size, x, y, 1, clr)
+}
+
+func (e *Editor) drawHUD(screen *ebiten.Image) {
+	top := len(e.rows) * e.tileSize
+	b := editorBrushes[e.brush]
+	lines := []string{
+		fmt.Sprintf("Brush %q %s  %dx%d", b.rune, b.name, e.width(), len(e.rows)),
+		"1-9/Tab brush  Ctrl+Z/Y undo  Ctrl+S save",
+		"Shift+Arrows resize  F5 play, Esc back",
+	}
+	status := "Layout OK"
+	if len(e.issues) > 0 {
+		status = fmt.Sprintf("%d errors, %d warnings: %v",
+			e.count(level.SeverityError), e.count(level.SeverityWarning), e.issues[0])
+	}
+	if e.message != "" {
+		status = e.message
+	}
+	lines = append(lines, status)
+	ebitenutil.DebugPrintAt(screen, strings.Join(lines, "\n"), 2, top)
+}
+
+func (e *Editor) Layout(outsideWidth, outsideHeight int) (int, int) {
+	if e.playtest != nil {
+		return e.playtest.Layout(outsideWidth, outsideHeight)
+	}
+	return max(e.width()*e.tileSize, editorMinWidth), len(e.rows)*e.tileSize + editorHUDHeight
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+
+	"github.com/sky0621/koro/internal/event"
+	"github.com/sky0621/koro/internal/fixed"
+	"github.com/sky0621/koro/internal/level"
+	"github.com/sky0621/koro/internal/render"
+)
+
+// effectKind names a timed effect on the player.
+type effectKind int
+
+const (
+	effectSpeed effectKind = iota
+	effectFreeze
+	effectMagnet
+	effectMultiplier
+	effectShield
+	effectPoison
+	effectCount
+)
+
+// effectTimers holds the frames left on each effect; zero means inactive.
+type effectTimers [effectCount]int
+
+// effectMods is the combined influence of every active effect on one frame.
+type effectMods struct {
+	speed        fixed.Num
+	scoreFactor  int
+	freezeGhosts bool
+	shield       bool
+	// magnetRange pulls in small pellets up to this many tiles away.
+	magnetRange int
+}
+
+// effect describes one timed effect. Adding an effect means adding an entry
+// to effectRegistry; the game loop only ever looks at the combined effectMods.
+type effect struct {
+	label    string
+	duration int
+	// stacks adds the duration on every pickup; otherwise it is refreshed.
+	stacks bool
+	color  color.Color
+	apply  func(m *effectMods)
+}
+
+var effectRegistry = [effectCount]effect{
+	effectSpeed: {
+		label: "Speed", duration: 300, stacks: true,
+		color: color.RGBA{80, 255, 120, 255},
+		apply: func(m *effectMods) { m.speed = m.speed.Mul(fixed.One * 3 / 2) },
+	},
+	effectFreeze: {
+		label: "Freeze", duration: 180, stacks: true,
+		color: color.RGBA{150, 220, 255, 255},
+		apply: func(m *effectMods) { m.freezeGhosts = true },
+	},
+	effectMagnet: {
+		label: "Magnet", duration: 420, stacks: true,
+		color: color.RGBA{255, 80, 80, 255},
+		apply: func(m *effectMods) { m.magnetRange = 2 },
+	},
+	effectMultiplier: {
+		label: "x2", duration: 600, stacks: true,
+		color: color.RGBA{255, 215, 0, 255},
+		apply: func(m *effectMods) { m.scoreFactor *= 2 },
+	},
+	effectShield: {
+		label: "Shield", duration: 900,
+		color: color.RGBA{180, 120, 255, 255},
+		apply: func(m *effectMods) { m.shield = true },
+	},
+	effectPoison: {
+		label: "Poison", duration: 120,
+		color: colorPoison,
+		apply: func(m *effectMods) { m.speed = m.speed.Mul(fixed.One * 3 / 5) },
+	},
+}
+
+// pickup is what eating a pellet does.
+type pickup struct {
+	score    int
+	onPickup func(g *Game)
+	// size is the drawn size as a fraction of a tile.
+	size  float64
+	color color.Color
+	round bool
+}
+
+const bonusPelletScore = 100
+
+var pickups = map[level.PelletType]pickup{
+	level.PelletSmall:      {score: pelletScore, size: 0.2, color: color.White},
+	level.PelletPower:      {score: powerPelletScore, size: 0.5, color: colorPowerPellet, onPickup: (*Game).activatePowerMode},
+	level.PelletSpeed:      effectPickup(effectSpeed),
+	level.PelletFreeze:     effectPickup(effectFreeze),
+	level.PelletMagnet:     effectPickup(effectMagnet),
+	level.PelletMultiplier: effectPickup(effectMultiplier),
+	level.PelletShield:     effectPickup(effectShield),
+}
+
+// hazards maps surfaces that harm the player to the effect they apply for as
+// long as the player stands on them.
+var hazards = map[level.Surface]effectKind{
+	level.SurfacePoison: effectPoison,
+}
+
+func effectPickup(kind effectKind) pickup {
+	return pickup{
+		score:    bonusPelletScore,
+		onPickup: func(g *Game) { g.grantEffect(kind) },
+		size:     0.3,
+		color:    effectRegistry[kind].color,
+		round:    true,
+	}
+}
+
+// grantEffect starts kind, or extends it when it is already running.
+func (g *Game) grantEffect(kind effectKind) {
+	e := effectRegistry[kind]
+	if g.effects[kind] == 0 {
+		g.emit(event.EffectStarted{Name: e.label, Frames: e.duration})
+	}
+	if e.stacks {
+		g.effects[kind] += e.duration
+	} else {
+		g.effects[kind] = max(g.effects[kind], e.duration)
+	}
+}
+
+// mods combines the active effects.
+func (g *Game) mods() effectMods {
+	m := effectMods{speed: fixed.One, scoreFactor: 1}
+	for kind, frames := range g.effects {
+		if frames > 0 {
+			effectRegistry[kind].apply(&m)
+		}
+	}
+	return m
+}
+
+// award adds points, scaled by any score multiplier, and returns the points added.
+func (g *Game) award(points int) int {
+	points *= g.mods().scoreFactor
+	g.score += points
+	return points
+}
+
+// collectPellet eats the pellet at pos, if any, and applies its pickup.
+func (g *Game) collectPellet(pos level.GridPos) {
+	p := g.level.ConsumePellet(pos.Col, pos.Row)
+	if p == level.PelletNone {
+		return
+	}
+	pk := pickups[p]
+	g.emit(event.PelletEaten{Pos: pos, Pellet: p, Points: g.award(pk.score)})
+	if pk.onPickup != nil {
+		pk.onPickup(g)
+	}
+}
+
+// handleHazards applies the effect of the surface under the player.
+func (g *Game) handleHazards() {
+	if kind, ok := hazards[g.level.ModifierAt(g.player.Tile()).Surface]; ok {
+		g.grantEffect(kind)
+	}
+}
+
+// updateEffects runs the per-frame part of the active effects and counts them down.
+func (g *Game) updateEffects() {
+	if r := g.mods().magnetRange; r > 0 {
+		at := g.player.Tile()
+		for row := at.Row - r; row <= at.Row+r; row++ {
+			for col := at.Col - r; col <= at.Col+r; col++ {
+				if g.level.PelletAt(col, row) == level.PelletSmall {
+					g.collectPellet(level.GridPos{Col: col, Row: row})
+				}
+			}
+		}
+	}
+	for kind := range g.effects {
+		if g.effects[kind] > 0 {
+			g.effects[kind]--
+		}
+	}
+}
+
+// effectStatus lists the active effects for the HUD.
+func (g *Game) effectStatus() string {
+	var text string
+	for kind, frames := range g.effects {
+		if frames > 0 {
+			text += fmt.Sprintf("  %s %ds", effectRegistry[kind].label, (frames+59)/60)
+		}
+	}
+	return text
+}
+
+// drawEffectBars draws a shrinking bar per active effect in the top-right corner.
+func (g *Game) drawEffectBars(screen *ebiten.Image) {
+	const width, height = 40.0, 4.0
+	w, _ := g.camera.size()
+	y := 2.0
+	for kind, frames := range g.effects {
+		if frames <= 0 {
+			continue
+		}
+		e := effectRegistry[kind]
+		filled := width * min(1, float64(frames)/float64(e.duration))
+		render.FillRect(screen, float64(w)-width-2, y, filled, height, e.color)
+		y += height + 2
+	}
+}
+
+// drawPellet draws a pellet of type p centred on cx, cy.
+func drawPellet(screen *ebiten.Image, cx, cy, tileSize float64, p level.PelletType) {
+	pk, ok := pickups[p]
+	if !ok {
+		return
+	}
+	size := tileSize * pk.size
+	if pk.round {
+		render.FillCircle(screen, cx, cy, size, pk.color)
+		render.FillCircle(screen, cx, cy, size*0.4, color.White)
+		return
+	}
+	render.FillRect(screen, cx-size/2, cy-size/2, size, size, pk.color)
+}
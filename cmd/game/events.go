@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"reflect"
+
+	"github.com/sky0621/koro/internal/event"
+)
+
+// beginEventFrame starts the frame about to run. When a rollback runs a
+// frame again, whatever its earlier, mispredicted run held back is dropped.
+func (g *Game) beginEventFrame() {
+	if g.heldEvents != nil {
+		delete(g.heldEvents, g.frame)
+	}
+}
+
+// emit publishes e at once, or holds it for publishConfirmed while the
+// current frame may still be rolled back.
+func (g *Game) emit(e event.Event) {
+	switch {
+	case g.events == nil:
+	case g.heldEvents != nil:
+		g.heldEvents[g.frame] = append(g.heldEvents[g.frame], e)
+	default:
+		g.eventFrame = g.frame
+		g.events.Publish(e)
+	}
+}
+
+// publishConfirmed publishes the held events of every frame up to and
+// including frame, in order.
+func (g *Game) publishConfirmed(frame uint32) {
+	for f := g.eventFrame + 1; f <= frame; f++ {
+		g.eventFrame = f
+		for _, e := range g.heldEvents[f] {
+			g.events.Publish(e)
+		}
+		delete(g.heldEvents, f)
+	}
+}
+
+// openEventLog opens path for appending, or stdout for "-".
+func openEventLog(path string) (io.WriteCloser, error) {
+	if path == "-" {
+		return nopCloser{os.Stdout}, nil
+	}
+	return os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+// logEvents writes every event to w as a JSON line tagged with its frame and type.
+func (g *Game) logEvents(w io.Writer) {
+	enc := json.NewEncoder(w)
+	g.events.Subscribe(func(e event.Event) {
+		line := struct {
+			Frame uint32      `json:"frame"`
+			Type  string      `json:"type"`
+			Event event.Event `json:"event"`
+		}{g.eventFrame, reflect.TypeOf(e).Name(), e}
+		if err := enc.Encode(line); err != nil {
+			log.Printf("event log: %v", err)
+		}
+	})
+}
@@ -1,40 +1,94 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"image/color"
-	"math"
+	"io/fs"
+	"log"
 	"math/rand"
+	"strings"
 	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
 
+	"github.com/sky0621/koro/internal/broadcast"
+	"github.com/sky0621/koro/internal/event"
+	"github.com/sky0621/koro/internal/fixed"
 	"github.com/sky0621/koro/internal/ghost"
 	"github.com/sky0621/koro/internal/input"
 	"github.com/sky0621/koro/internal/koro"
 	"github.com/sky0621/koro/internal/level"
+	"github.com/sky0621/koro/internal/netplay"
 	"github.com/sky0621/koro/internal/render"
+	"github.com/sky0621/koro/internal/rng"
+	"github.com/sky0621/koro/internal/sound"
 )
 
 type Game struct {
 	level    *level.Level
 	tileSize float64
+	// art replaces the flat tile colours for levels imported from Tiled.
+	art    *tileArt
+	camera *camera
+
+	mode      GameMode
+	abilities koro.Abilities
+	// cornerWindow is Koro's pre-turn window in pixels; see koro.Koro.CornerWindow.
+	cornerWindow float64
+	collision    collisionMode
+	player       *koro.Koro
+	ghosts       []*ghost.Ghost
+	input        *input.Manager
+	rivalInput   *input.Manager
+
+	score       int
+	lives       int
+	levelNumber int
+	state       GameState
+	readyTimer  int
+	powerTimer  int
+	effects     effectTimers
+	// playerTile is the tile the player stood on last frame, so switches
+	// only fire when stepped onto.
+	playerTile level.GridPos
+
+	players      []playerSlot
+	activePlayer int
+
+	playerSpawn level.GridPos
+	walkable    []level.GridPos
+	rng         *rand.Rand
+	rngSource   *rng.Source
+	frame       uint32
+
+	net       *netplayState
+	broadcast *broadcaster
+	savePath  string
+	paused    bool
+	focused   bool
+	rewind    *rewindBuffer
+	sound     *sound.Mixer
+
+	// events publishes what happens in the simulation. Under rollback a
+	// frame can run again with different inputs, so its events are held
+	// back until the frame is confirmed; eventFrame is the frame of the
+	// event being published.
+	events     *event.Bus
+	heldEvents map[uint32][]event.Event
+	eventFrame uint32
+}
 
-	player *koro.Koro
-	ghosts []*ghost.Ghost
-	input  *input.Manager
-
-	score      int
-	lives      int
-	state      GameState
-	readyTimer int
-	powerTimer int
-
-	playerSpawnX float64
-	playerSpawnY float64
-	walkable     []level.GridPos
-	rng          *rand.Rand
+// frameInput is everything the simulation reads from the players in one frame.
+type frameInput struct {
+	player koro.Direction
+	rival  koro.Direction
+	start  bool
+	dash   bool
+	pulse  bool
 }
 
 type GameState int
@@ -46,42 +100,125 @@ const (
 	StateGameOver
 )
 
+// GameMode selects how many people play and what they control.
+type GameMode int
+
+const (
+	ModeClassic GameMode = iota
+	// ModeVersus lets a second player steer the first ghost.
+	ModeVersus
+	// ModeAlternate is the arcade two-player mode where turns swap on every death.
+	ModeAlternate
+)
+
+func (m GameMode) String() string {
+	switch m {
+	case ModeVersus:
+		return "versus"
+	case ModeAlternate:
+		return "alternate"
+	default:
+		return "classic"
+	}
+}
+
+// modeAbilities enables Koro's abilities per mode. Versus leaves out the
+// pulse so the rival ghost is never stunned by a human opponent.
+var modeAbilities = map[GameMode]koro.Abilities{
+	ModeClassic:   {Dash: true, Pulse: true},
+	ModeVersus:    {Dash: true},
+	ModeAlternate: {Dash: true, Pulse: true},
+}
+
+func parseMode(name string) (GameMode, error) {
+	switch name {
+	case "classic":
+		return ModeClassic, nil
+	case "versus":
+		return ModeVersus, nil
+	case "alternate":
+		return ModeAlternate, nil
+	default:
+		return ModeClassic, fmt.Errorf("unknown mode %q", name)
+	}
+}
+
 const (
-	startLives         = 3
-	pelletScore        = 10
-	powerPelletScore   = 50
-	ghostScore         = 200
-	powerModeDuration  = 600
-	readyDelayFrames   = 60
-	collisionShrinkage = 0.85
+	startLives        = 3
+	pelletScore       = 10
+	powerPelletScore  = 50
+	ghostScore        = 200
+	powerModeDuration = 600
+	readyDelayFrames  = 60
+	// playerSwapDelayFrames keeps the "PLAYER N READY" banner up a little longer.
+	playerSwapDelayFrames = 120
+	// collisionShrinkage is how close, in tiles, two actors' centres get
+	// before they touch in swept collision mode.
+	collisionShrinkage = fixed.One * 85 / 100
+	// playerSpeed is in tiles per frame.
+	playerSpeed         = fixed.One / 10
+	pulseStunFrames     = 90
+	defaultCornerWindow = 5
 )
 
 var (
 	colorWall        = color.NRGBA{0, 0, 80, 255}
 	colorWarp        = color.NRGBA{30, 30, 90, 255}
 	colorFloor       = color.NRGBA{10, 10, 10, 255}
+	colorTunnel      = color.NRGBA{20, 20, 45, 255}
+	colorMud         = color.NRGBA{45, 30, 15, 255}
+	colorIce         = color.NRGBA{30, 55, 70, 255}
+	colorConveyor    = color.NRGBA{35, 35, 35, 255}
+	colorConveyorArr = color.NRGBA{90, 90, 90, 255}
+	colorPoison      = color.NRGBA{40, 70, 20, 255}
 	colorPlayer      = color.RGBA{255, 255, 0, 255}
 	colorPowerPellet = color.RGBA{255, 165, 0, 255}
+	colorSwitch      = color.NRGBA{200, 200, 200, 255}
+	colorGate        = color.NRGBA{120, 90, 200, 255}
 )
 
+// keyColors tints doors and keys 'a' to 'e' so matching pairs are easy to spot.
+var keyColors = []color.Color{
+	color.RGBA{230, 60, 60, 255},
+	color.RGBA{60, 200, 90, 255},
+	color.RGBA{70, 130, 255, 255},
+	color.RGBA{240, 200, 40, 255},
+	color.RGBA{200, 80, 220, 255},
+}
+
 var ghostColors = []color.Color{
 	color.RGBA{255, 0, 0, 255},
 	color.RGBA{0, 255, 255, 255},
 	color.RGBA{255, 105, 180, 255},
 }
 
-func newGame() *Game {
-	lvl := level.DefaultLevel()
+func newGame(mode GameMode, seed int64, lvl *level.Level) *Game {
 	g := &Game{
-		level:      lvl,
-		tileSize:   float64(lvl.TileSize),
-		input:      input.NewManager(),
-		lives:      startLives,
-		score:      0,
-		state:      StateReady,
-		readyTimer: readyDelayFrames,
-		walkable:   lvl.WalkableTiles(),
-		rng:        rand.New(rand.NewSource(time.Now().UnixNano())),
+		level:        lvl,
+		tileSize:     float64(lvl.TileSize),
+		mode:         mode,
+		abilities:    modeAbilities[mode],
+		cornerWindow: defaultCornerWindow,
+		input:        input.NewManager(),
+		lives:        startLives,
+		score:        0,
+		levelNumber:  1,
+		state:        StateReady,
+		readyTimer:   readyDelayFrames,
+		walkable:     lvl.WalkableTiles(),
+		art:          newTileArt(lvl),
+		camera:       newCamera(lvl, mode == ModeVersus),
+		events:       event.NewBus(),
+	}
+	g.rng, g.rngSource = rng.New(seed)
+	if mode == ModeVersus {
+		p1 := input.ArrowKeys
+		p1.Gamepad = 0
+		g.input = input.NewManagerWithBindings(p1)
+		g.rivalInput = input.NewManagerWithBindings(input.WASDKeys)
+	}
+	if mode == ModeAlternate {
+		g.resetPlayers(alternatePlayers)
 	}
 	g.setupActors()
 	return g
@@ -89,124 +226,312 @@ func newGame() *Game {
 
 func (g *Game) setupActors() {
 	g.tileSize = float64(g.level.TileSize)
-	g.playerSpawnX = 7 * g.tileSize
-	g.playerSpawnY = 11 * g.tileSize
-	g.player = koro.New(g.playerSpawnX, g.playerSpawnY, g.tileSize)
-	g.player.SetSpeed(1.6)
+	g.playerSpawn = g.level.PlayerSpawn()
+	g.player = koro.New(g.playerSpawn, g.tileSize)
+	g.playerTile = g.playerSpawn
+	g.player.SetSpeed(playerSpeed)
+	g.player.SetAbilities(g.abilities)
+	g.player.CornerWindow = g.cornerTiles()
 	positions := g.randomSpawnPositions(len(ghostColors))
 	g.ghosts = make([]*ghost.Ghost, 0, len(ghostColors))
 	for i, clr := range ghostColors {
-		pos := positions[i%len(positions)]
-		x := float64(pos.Col) * g.tileSize
-		y := float64(pos.Row) * g.tileSize
-		gh := ghost.New(x, y, g.tileSize, clr)
+		gh := ghost.New(positions[i%len(positions)], g.tileSize, clr)
+		gh.Seed(g.rng.Int63())
 		g.ghosts = append(g.ghosts, gh)
 	}
+	if g.mode == ModeVersus {
+		g.rival().SetPlayerControlled(true)
+	}
 	g.powerTimer = 0
+	g.effects = effectTimers{}
+}
+
+// cornerTiles converts the pixel corner window to tiles for Koro.
+func (g *Game) cornerTiles() fixed.Num {
+	return fixed.FromFloat(g.cornerWindow / g.tileSize)
+}
+
+// rival returns the ghost steered by the second player in versus mode.
+func (g *Game) rival() *ghost.Ghost {
+	return g.ghosts[0]
 }
 
 func (g *Game) Update() error {
+	if ebiten.IsWindowBeingClosed() {
+		if g.net != nil {
+			g.net.session.Close()
+		}
+		g.autosave()
+		return ebiten.Termination
+	}
+
+	var err error
+	switch {
+	case g.net != nil:
+		err = g.updateNetplay()
+	case g.rewind != nil && g.updateRewind():
+		// Time travel owns the simulation while it is active.
+	case !g.updatePause():
+		prev := g.state
+		g.step(g.pollInput())
+		if g.state == StateGameOver && prev != StateGameOver {
+			g.autosave()
+		}
+		if g.rewind != nil {
+			g.rewind.push(g.snapshot())
+		}
+	}
+	g.camera.follow(g.level, g.cameraTarget())
+	if g.sound != nil {
+		g.updateSound()
+	}
+	if g.broadcast != nil {
+		g.publishFrame()
+	}
+	return err
+}
+
+// updatePause toggles pause with P and pauses on its own when the window loses
+// focus, e.g. when a mobile app is sent to the background. Both autosave.
+// It reports whether the simulation should stay frozen this frame.
+func (g *Game) updatePause() bool {
+	focused := ebiten.IsFocused()
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyP):
+		g.paused = !g.paused
+		if g.paused {
+			g.autosave()
+		}
+	case g.focused && !focused && !g.paused:
+		g.paused = true
+		g.autosave()
+	}
+	g.focused = focused
+	return g.paused
+}
+
+func (g *Game) pollInput() frameInput {
+	g.input.Update()
+	in := frameInput{
+		player: g.input.Direction(),
+		start:  ebiten.IsKeyPressed(ebiten.KeyEnter),
+		dash:   g.input.Action(koro.AbilityDash),
+		pulse:  g.input.Action(koro.AbilityPulse),
+	}
+	if g.rivalInput != nil {
+		g.rivalInput.Update()
+		in.rival = g.rivalInput.Direction()
+	}
+	return in
+}
+
+// step advances the simulation by one frame. It must only depend on the game
+// state and in so that networked peers stay in lockstep.
+func (g *Game) step(in frameInput) {
+	g.frame++
+	g.beginEventFrame()
 	switch g.state {
 	case StateReady:
 		if g.readyTimer > 0 {
 			g.readyTimer--
-			return nil
+			return
 		}
 		g.state = StatePlaying
 	case StatePlaying:
-		g.handleInput()
+		placed := g.framePlacements()
+		g.handleInput(in)
+		g.player.SetSpeed(playerSpeed.Mul(g.mods().speed))
 		g.player.Update(g.level)
 		g.handlePelletPickup()
+		g.handleFeatures()
+		g.handleHazards()
 		g.updateGhosts()
 		g.updatePowerTimer()
-		g.checkGhostCollisions()
+		g.updateEffects()
+		g.checkGhostCollisions(placed)
 		if g.state == StateGameOver {
-			return nil
+			return
 		}
 		if g.level.RemainingPellets() == 0 {
 			g.state = StateCleared
 			g.readyTimer = readyDelayFrames
+			g.emit(event.LevelCleared{Level: g.levelNumber, Score: g.score})
 		}
 	case StateCleared:
-		if ebiten.IsKeyPressed(ebiten.KeyEnter) {
+		if in.start {
 			g.resetLevel(true)
 		}
 	case StateGameOver:
-		if ebiten.IsKeyPressed(ebiten.KeyEnter) {
+		if in.start {
 			g.resetLevel(false)
 		}
 	}
-	return nil
 }
 
 func (g *Game) Draw(screen *ebiten.Image) {
-	g.drawLevel(screen)
-	g.drawPellets(screen)
-	g.drawGhosts(screen)
-	render.DrawPlayer(screen, g.player.X, g.player.Y, g.player.Size, g.player.Direction(), colorPlayer, colorFloor)
+	v := g.camera.view()
+	if g.art != nil {
+		render.FillRect(screen, 0, 0, v.w, v.h, colorFloor)
+		g.art.draw(screen, g.level, v)
+	} else {
+		drawLevel(screen, g.level, v)
+	}
+	drawFeatures(screen, g.level, v)
+	drawPellets(screen, g.level, v)
+	g.drawGhosts(screen, v)
+	px, py := g.player.Pixels()
+	render.DrawPlayer(screen, px-v.x, py-v.y, g.player.Size, g.player.Direction(), colorPlayer, colorFloor)
+	if g.rewind != nil {
+		g.drawDebugOverlay(screen, v)
+	}
 	g.drawHUD(screen)
+	g.drawEffectBars(screen)
+	g.drawAbilityBars(screen)
 }
 
 func (g *Game) Layout(outsideWidth, outsideHeight int) (int, int) {
-	return g.level.PixelWidth(), g.level.PixelHeight()
+	return g.camera.size()
 }
 
-func (g *Game) drawLevel(screen *ebiten.Image) {
-	tileSize := g.tileSize
-	for row := 0; row < g.level.Height; row++ {
-		for col := 0; col < g.level.Width; col++ {
-			x := float64(col) * tileSize
-			y := float64(row) * tileSize
+// drawLevel draws the tiles inside v.
+func drawLevel(screen *ebiten.Image, lvl *level.Level, v view) {
+	tileSize := float64(lvl.TileSize)
+	col0, row0, col1, row1 := v.tiles(lvl)
+	for row := row0; row < row1; row++ {
+		for col := col0; col < col1; col++ {
+			x := float64(col)*tileSize - v.x
+			y := float64(row)*tileSize - v.y
 			var c color.Color
-			switch g.level.TileAt(col, row) {
-			case level.TileWall:
+			mod := lvl.ModifierAt(level.GridPos{Col: col, Row: row})
+			switch {
+			case lvl.TileAt(col, row) == level.TileWall:
 				c = colorWall
-			case level.TileWarp:
+			case lvl.TileAt(col, row) == level.TileWarp:
 				c = colorWarp
+			case mod.Surface == level.SurfaceTunnel:
+				c = colorTunnel
+			case mod.Surface == level.SurfaceMud:
+				c = colorMud
+			case mod.Surface == level.SurfaceIce:
+				c = colorIce
+			case mod.Surface == level.SurfaceConveyor:
+				c = colorConveyor
+			case mod.Surface == level.SurfacePoison:
+				c = colorPoison
 			default:
 				c = colorFloor
 			}
 			render.FillRect(screen, x, y, tileSize, tileSize, c)
+			if mod.Surface == level.SurfaceConveyor {
+				drawConveyorArrow(screen, x+tileSize/2, y+tileSize/2, tileSize, mod.Flow)
+			}
+		}
+	}
+}
+
+// drawConveyorArrow draws a chevron pointing along the conveyor's flow.
+func drawConveyorArrow(screen *ebiten.Image, cx, cy, tileSize float64, flow level.GridPos) {
+	fx, fy := float64(flow.Col), float64(flow.Row)
+	tip := tileSize * 0.25
+	// The perpendicular of (fx, fy) spreads the chevron's arms.
+	px, py := -fy, fx
+	tipX, tipY := cx+fx*tip, cy+fy*tip
+	for _, side := range [...]float64{-1, 1} {
+		armX := cx - fx*tip/2 + px*side*tip
+		armY := cy - fy*tip/2 + py*side*tip
+		render.StrokeLine(screen, armX, armY, tipX, tipY, 2, colorConveyorArr)
+	}
+}
+
+// drawFeatures draws the doors, keys, switches and gates inside v in their current state.
+func drawFeatures(screen *ebiten.Image, lvl *level.Level, v view) {
+	tileSize := float64(lvl.TileSize)
+	col0, row0, col1, row1 := v.tiles(lvl)
+	for _, pos := range lvl.Features() {
+		if pos.Col < col0 || pos.Col >= col1 || pos.Row < row0 || pos.Row >= row1 {
+			continue
+		}
+		f, _ := lvl.FeatureAt(pos)
+		drawFeature(screen, float64(pos.Col)*tileSize-v.x, float64(pos.Row)*tileSize-v.y, tileSize, f)
+	}
+}
+
+// drawFeature draws one interactive tile at x, y.
+func drawFeature(screen *ebiten.Image, x, y, size float64, f level.Feature) {
+	half := size / 2
+	switch f.Kind {
+	case level.FeatureDoor:
+		if f.Open {
+			return
+		}
+		render.FillRect(screen, x+1, y+1, size-2, size-2, keyColor(f.Key))
+		render.FillCircle(screen, x+half, y+half*0.8, size*0.1, colorFloor)
+		render.FillRect(screen, x+half-size*0.04, y+half*0.8, size*0.08, size*0.25, colorFloor)
+	case level.FeatureKey:
+		if f.Open {
+			return
+		}
+		clr := keyColor(f.Key)
+		render.FillCircle(screen, x+size*0.35, y+half, size*0.15, clr)
+		render.FillRect(screen, x+size*0.35, y+half-size*0.05, size*0.45, size*0.1, clr)
+		render.FillRect(screen, x+size*0.7, y+half, size*0.08, size*0.15, clr)
+	case level.FeatureSwitch:
+		render.FillRect(screen, x+size*0.25, y+size*0.25, size*0.5, size*0.5, colorSwitch)
+		render.FillRect(screen, x+size*0.35, y+size*0.35, size*0.3, size*0.3, colorGate)
+	case level.FeatureGate:
+		if f.Open {
+			strokeTile(screen, x+1, y+1, size-2, colorGate)
+			return
 		}
+		for i := 1; i <= 3; i++ {
+			bx := x + size*float64(i)/4
+			render.StrokeLine(screen, bx, y, bx, y+size, 2, colorGate)
+		}
+		render.StrokeLine(screen, x, y+half, x+size, y+half, 2, colorGate)
 	}
 }
 
-func (g *Game) drawPellets(screen *ebiten.Image) {
-	tileSize := g.tileSize
+func keyColor(key byte) color.Color {
+	return keyColors[int(key-'a')%len(keyColors)]
+}
+
+func drawPellets(screen *ebiten.Image, lvl *level.Level, v view) {
+	tileSize := float64(lvl.TileSize)
 	half := tileSize / 2
-	for row := 0; row < g.level.Height; row++ {
-		for col := 0; col < g.level.Width; col++ {
-			var size float64
-			var clr color.Color
-			switch g.level.PelletAt(col, row) {
-			case level.PelletSmall:
-				size = tileSize * 0.2
-				clr = color.White
-			case level.PelletPower:
-				size = tileSize * 0.5
-				clr = colorPowerPellet
-			default:
-				continue
-			}
-			centerX := float64(col)*tileSize + half
-			centerY := float64(row)*tileSize + half
-			render.FillRect(screen, centerX-size/2, centerY-size/2, size, size, clr)
+	col0, row0, col1, row1 := v.tiles(lvl)
+	for row := row0; row < row1; row++ {
+		for col := col0; col < col1; col++ {
+			centerX := float64(col)*tileSize + half - v.x
+			centerY := float64(row)*tileSize + half - v.y
+			drawPellet(screen, centerX, centerY, tileSize, lvl.PelletAt(col, row))
 		}
 	}
 }
 
-func (g *Game) drawGhosts(screen *ebiten.Image) {
+func (g *Game) drawGhosts(screen *ebiten.Image, v view) {
 	for _, gh := range g.ghosts {
-		x, y := gh.Position()
-		render.DrawGhost(screen, x, y, gh.Size(), gh.Color(), gh.IsFrightened())
+		x, y := gh.Pixels()
+		render.DrawGhost(screen, x-v.x, y-v.y, gh.Size(), gh.Color(), gh.IsFrightened())
 	}
 }
 
 func (g *Game) drawHUD(screen *ebiten.Image) {
-	text := fmt.Sprintf("Score: %d  Lives: %d", g.score, g.lives)
+	text := fmt.Sprintf("Score: %d  Lives: %d  Level: %d", g.score, g.lives, g.levelNumber)
+	switch g.mode {
+	case ModeVersus:
+		if g.net == nil {
+			text += "  P2: WASD"
+		}
+	case ModeAlternate:
+		text = fmt.Sprintf("P%d ", g.activePlayer+1) + text
+	}
 	switch g.state {
 	case StateReady:
-		text += "  Ready!"
+		if g.mode == ModeAlternate {
+			text += fmt.Sprintf("\nPLAYER %d READY", g.activePlayer+1)
+		} else {
+			text += "  Ready!"
+		}
 	case StateCleared:
 		text += "  LEVEL CLEAR - Press Enter"
 	case StateGameOver:
@@ -215,132 +540,220 @@ func (g *Game) drawHUD(screen *ebiten.Image) {
 	if g.powerTimer > 0 {
 		text += fmt.Sprintf("  Power %ds", g.powerTimer/60)
 	}
+	text += g.effectStatus()
+	text += g.abilityStatus()
+	text += g.soundStatus()
+	if g.paused {
+		text += "\nPAUSED - Press P"
+	}
+	if g.net != nil {
+		text += g.netplayStatus()
+	}
+	if g.rewind != nil {
+		text += g.rewindStatus()
+	}
 	ebitenutil.DebugPrint(screen, text)
 }
 
-func (g *Game) handleInput() {
-	g.input.Update()
-	g.player.SetIntentDirection(g.input.Direction())
+func (g *Game) handleInput(in frameInput) {
+	g.player.SetIntentDirection(in.player)
+	if in.dash {
+		g.player.Use(koro.AbilityDash)
+	}
+	if in.pulse && g.player.Use(koro.AbilityPulse) {
+		g.stunNearbyGhosts()
+	}
+	if g.rival().IsPlayerControlled() {
+		g.rival().SetControlDirection(in.rival)
+	}
 }
 
 func (g *Game) handlePelletPickup() {
-	cx, cy := g.player.Center()
-	grid := g.level.GridForPixel(cx, cy)
-	switch g.level.ConsumePellet(grid.Col, grid.Row) {
-	case level.PelletSmall:
-		g.score += pelletScore
-	case level.PelletPower:
-		g.score += powerPelletScore
-		g.activatePowerMode()
+	g.collectPellet(g.player.Tile())
+}
+
+// handleFeatures collects keys under the player and presses switches they step onto.
+func (g *Game) handleFeatures() {
+	grid := g.player.Tile()
+	entered := grid != g.playerTile
+	g.playerTile = grid
+	rev := g.level.Revision()
+	g.level.CollectKey(grid)
+	if entered {
+		g.level.PressSwitch(grid, g.tileOccupied)
+	}
+	if g.level.Revision() != rev {
+		g.walkable = g.level.WalkableTiles()
+	}
+}
+
+// tileOccupied reports whether any actor overlaps pos, so a gate never closes on one.
+func (g *Game) tileOccupied(pos level.GridPos) bool {
+	bodies := []*koro.Koro{g.player}
+	for _, gh := range g.ghosts {
+		bodies = append(bodies, gh.Body())
+	}
+	for _, b := range bodies {
+		x, y := b.Pos()
+		col0, row0 := x.Floor(), y.Floor()
+		col1, row1 := (x + fixed.One - 1).Floor(), (y + fixed.One - 1).Floor()
+		if pos.Col >= col0 && pos.Col <= col1 && pos.Row >= row0 && pos.Row <= row1 {
+			return true
+		}
+	}
+	return false
+}
+
+// stunNearbyGhosts stuns every ghost on or next to Koro's tile.
+func (g *Game) stunNearbyGhosts() {
+	at := g.player.Tile()
+	for _, gh := range g.ghosts {
+		pos := gh.Body().Tile()
+		if abs(pos.Col-at.Col) <= 1 && abs(pos.Row-at.Row) <= 1 {
+			gh.Stun(pulseStunFrames)
+		}
 	}
 }
 
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
 func (g *Game) activatePowerMode() {
 	g.powerTimer = powerModeDuration
 	for _, gh := range g.ghosts {
 		gh.SetFrightened(powerModeDuration)
 	}
+	g.emit(event.PowerStarted{Frames: powerModeDuration})
 }
 
 func (g *Game) updateGhosts() {
-	px, py := g.player.Center()
+	if g.mods().freezeGhosts {
+		// Frozen ghosts stay put, but power mode and stuns still wear off
+		// on schedule.
+		for _, gh := range g.ghosts {
+			gh.Freeze()
+		}
+		return
+	}
+	target := g.player.Tile()
 	for _, gh := range g.ghosts {
-		gh.Update(g.level, px, py)
+		gh.Update(g.level, target)
 	}
 }
 
 func (g *Game) updatePowerTimer() {
 	if g.powerTimer > 0 {
 		g.powerTimer--
-	}
-}
-
-func (g *Game) checkGhostCollisions() {
-	px, py := g.player.Center()
-	playerRadius := g.player.Size / 2 * collisionShrinkage
-	for _, gh := range g.ghosts {
-		cx, cy := gh.Body().Center()
-		ghostRadius := gh.Size() / 2 * collisionShrinkage
-		if math.Hypot(px-cx, py-cy) <= playerRadius+ghostRadius {
-			if gh.IsFrightened() {
-				g.score += ghostScore
-				g.respawnGhostRandom(gh)
-			} else {
-				g.loseLife()
-			}
-			return
+		if g.powerTimer == 0 {
+			g.emit(event.PowerEnded{})
 		}
 	}
 }
 
 func (g *Game) loseLife() {
 	g.lives--
+	g.emit(event.PlayerDied{Pos: g.player.Tile(), LivesLeft: g.lives})
+	if g.mode == ModeAlternate && g.nextPlayerTurn() {
+		return
+	}
 	if g.lives <= 0 {
 		g.state = StateGameOver
+		g.emit(event.GameOver{Score: g.score})
 		return
 	}
 	g.powerTimer = 0
+	g.effects = effectTimers{}
 	g.resetActorPositions()
 	g.state = StateReady
 	g.readyTimer = readyDelayFrames
 }
 
 func (g *Game) resetActorPositions() {
-	g.player.SetPosition(g.playerSpawnX, g.playerSpawnY)
+	g.player.SetPosition(g.playerSpawn)
+	g.playerTile = g.level.PlayerSpawn()
 	g.respawnAllGhosts()
 }
 
 func (g *Game) resetLevel(keepScore bool) {
-	g.level = level.DefaultLevel()
+	g.level = g.freshLevel()
 	g.walkable = g.level.WalkableTiles()
 	g.setupActors()
-	if !keepScore {
+	if keepScore {
+		g.levelNumber++
+	} else {
 		g.score = 0
 		g.lives = startLives
+		g.levelNumber = 1
+		if g.mode == ModeAlternate {
+			g.resetPlayers(alternatePlayers)
+		}
 	}
 	g.state = StateReady
 	g.readyTimer = readyDelayFrames
 }
 
+// freshLevel rebuilds the current stage with every pellet back in place.
+func (g *Game) freshLevel() *level.Level {
+	lvl, err := level.New(g.level.Layout(), g.level.TileSize)
+	if err != nil {
+		// The layout was already accepted once, so this cannot happen.
+		panic(err)
+	}
+	lvl.ID = g.level.ID
+	return lvl
+}
+
+// ghostSpawnTiles returns the level's 'G' markers, or every walkable tile when it has none.
+func (g *Game) ghostSpawnTiles() []level.GridPos {
+	if spawns := g.level.GhostSpawns(); len(spawns) > 0 {
+		return spawns
+	}
+	return g.walkable
+}
+
 func (g *Game) randomSpawnPositions(count int) []level.GridPos {
+	tiles := g.ghostSpawnTiles()
 	excludes := map[level.GridPos]struct{}{}
-	playerGrid := g.level.GridForPixel(g.playerSpawnX+g.player.Size/2, g.playerSpawnY+g.player.Size/2)
-	excludes[playerGrid] = struct{}{}
+	excludes[g.playerSpawn] = struct{}{}
 	result := make([]level.GridPos, 0, count)
 	for len(result) < count {
 		pos := g.randomSpawnPosition(excludes)
 		result = append(result, pos)
 		excludes[pos] = struct{}{}
-		if len(excludes) == len(g.walkable) {
+		if len(excludes) >= len(tiles) {
 			break
 		}
 	}
-	for len(result) < count {
-		result = append(result, g.walkable[g.rng.Intn(len(g.walkable))])
+	for len(result) < count && len(tiles) > 0 {
+		result = append(result, tiles[g.rng.Intn(len(tiles))])
 	}
 	return result
 }
 
 func (g *Game) randomSpawnPosition(excludes map[level.GridPos]struct{}) level.GridPos {
-	if len(g.walkable) == 0 {
+	tiles := g.ghostSpawnTiles()
+	if len(tiles) == 0 {
 		return level.GridPos{}
 	}
-	start := g.rng.Intn(len(g.walkable))
-	for i := 0; i < len(g.walkable); i++ {
-		idx := (start + i) % len(g.walkable)
-		pos := g.walkable[idx]
+	start := g.rng.Intn(len(tiles))
+	for i := 0; i < len(tiles); i++ {
+		idx := (start + i) % len(tiles)
+		pos := tiles[idx]
 		if _, blocked := excludes[pos]; !blocked {
 			return pos
 		}
 	}
-	return g.walkable[start]
+	return tiles[start]
 }
 
 func (g *Game) respawnGhostRandom(gh *ghost.Ghost) {
 	excludes := g.occupiedPositions(gh)
-	excludes[g.gridForGhost(gh)] = struct{}{}
-	pos := g.randomSpawnPosition(excludes)
-	g.placeGhost(gh, pos)
+	excludes[gh.Body().Tile()] = struct{}{}
+	gh.RespawnAt(g.randomSpawnPosition(excludes))
 }
 
 func (g *Game) respawnAllGhosts() {
@@ -351,33 +764,180 @@ func (g *Game) respawnAllGhosts() {
 
 func (g *Game) occupiedPositions(except *ghost.Ghost) map[level.GridPos]struct{} {
 	excludes := map[level.GridPos]struct{}{}
-	px, py := g.player.Center()
-	excludes[g.level.GridForPixel(px, py)] = struct{}{}
+	excludes[g.player.Tile()] = struct{}{}
 	for _, gh := range g.ghosts {
 		if gh == except {
 			continue
 		}
-		excludes[g.gridForGhost(gh)] = struct{}{}
+		excludes[gh.Body().Tile()] = struct{}{}
 	}
 	return excludes
 }
 
-func (g *Game) placeGhost(gh *ghost.Ghost, pos level.GridPos) {
-	x := float64(pos.Col) * g.tileSize
-	y := float64(pos.Row) * g.tileSize
-	gh.RespawnAt(x, y)
+// loadLevel resolves the -level flag: "daily", a generated level ID or a layout file.
+func loadLevel(name string) (*level.Level, error) {
+	switch {
+	case name == "daily":
+		return level.ByID(level.DailyID(time.Now()))
+	case strings.HasPrefix(name, "gen:"):
+		return level.ByID(name)
+	default:
+		return level.LoadFile(name)
+	}
 }
 
-func (g *Game) gridForGhost(gh *ghost.Ghost) level.GridPos {
-	x, y := gh.Position()
-	cx := x + gh.Size()/2
-	cy := y + gh.Size()/2
-	return g.level.GridForPixel(cx, cy)
+// resumeMatches reports whether the saved game g is what -mode and -level
+// ask for. Flags left at their defaults accept whatever was saved.
+func resumeMatches(g *Game, mode GameMode, lvl *level.Level) bool {
+	set := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	switch {
+	case set["mode"] && g.mode != mode:
+		log.Printf("not resuming the saved %s game: -mode asks for %s", g.mode, mode)
+		return false
+	case set["level"] && g.level.ID != lvl.ID:
+		log.Printf("not resuming the saved game on %s: -level asks for %s", g.level.ID, lvl.ID)
+		return false
+	default:
+		return true
+	}
 }
 
 func main() {
-	g := newGame()
-	ebiten.SetWindowSize(g.level.PixelWidth()*2, g.level.PixelHeight()*2)
+	modeName := flag.String("mode", "classic", "game mode: classic, versus or alternate")
+	hostAddr := flag.String("host", "", "host an online versus match on this address (e.g. :7777)")
+	joinAddr := flag.String("join", "", "join an online versus match at this address (e.g. 127.0.0.1:7777)")
+	inputDelay := flag.Int("delay", -1, "online input delay in frames (default 3, or 1 with -rollback)")
+	rollback := flag.Bool("rollback", false, "predict the peer's input and roll back on mispredictions")
+	broadcastAddr := flag.String("broadcast", "", "stream the game to spectators on this address (e.g. :8080)")
+	spectateURL := flag.String("spectate", "", "watch a broadcast (e.g. ws://localhost:8080/spectate)")
+	savePath := flag.String("save", defaultSavePath(), "autosave file used to resume local games")
+	fresh := flag.Bool("new", false, "ignore any autosave and start a new game")
+	levelPath := flag.String("level", "", "play a layout file, a generated maze (gen:<seed>) or today's maze (daily) instead of the built-in one")
+	editPath := flag.String("edit", "", "open a layout file in the level editor (created on save if missing)")
+	corner := flag.Float64("corner", defaultCornerWindow, "pixels before or after a tile centre where Koro may cut a corner (0 disables; online games use the default)")
+	collision := flag.String("collision", "swept", "how Koro meets ghosts: swept (closest approach over the frame) or tile (arcade same-tile rule); online games use swept")
+	eventLog := flag.String("events", "", "append gameplay events to this file as JSON lines (- for stdout)")
+	volume := flag.Float64("volume", 1, "master volume from 0 to 1")
+	musicVolume := flag.Float64("music-volume", 0.6, "music volume from 0 to 1")
+	sfxVolume := flag.Float64("sfx-volume", 1, "sound effect volume from 0 to 1")
+	mute := flag.Bool("mute", false, "start with sound muted (M toggles)")
+	debug := flag.Bool("debug", false, "record recent frames for rewinding and show ghost AI decisions")
+	flag.Parse()
+
+	if *spectateURL != "" {
+		client, err := broadcast.Dial(*spectateURL)
+		if err != nil {
+			panic(err)
+		}
+		ebiten.SetWindowTitle("Koro Game - Spectator")
+		if err := ebiten.RunGame(newSpectator(client)); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	if *editPath != "" {
+		e, err := newEditor(*editPath)
+		if err != nil {
+			panic(err)
+		}
+		w, h := e.Layout(0, 0)
+		ebiten.SetWindowSize(w*2, h*2)
+		ebiten.SetWindowTitle("Koro Game - Editor")
+		if err := ebiten.RunGame(e); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	if *inputDelay < 0 {
+		*inputDelay = netplay.DefaultInputDelay
+		if *rollback {
+			*inputDelay = netplay.DefaultRollbackDelay
+		}
+	}
+	mode, err := parseMode(*modeName)
+	if err != nil {
+		panic(err)
+	}
+	collisionRule, err := parseCollisionMode(*collision)
+	if err != nil {
+		panic(err)
+	}
+	lvl := level.DefaultLevel()
+	if *levelPath != "" {
+		if lvl, err = loadLevel(*levelPath); err != nil {
+			panic(err)
+		}
+	}
+
+	var session *netplay.Session
+	switch {
+	case *hostAddr != "":
+		log.Printf("waiting for a peer on %s", *hostAddr)
+		session, err = netplay.Host(*hostAddr, time.Now().UnixNano(), *inputDelay, lvl.ID)
+	case *joinAddr != "":
+		session, err = netplay.Join(*joinAddr, lvl.ID)
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	var g *Game
+	if session != nil {
+		g = newGame(ModeVersus, session.Seed(), lvl)
+		g.net = newNetplayState(g, session, *rollback)
+		ebiten.SetRunnableOnUnfocused(true)
+	} else {
+		if !*fresh {
+			g, err = loadGame(*savePath)
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				log.Printf("could not resume saved game: %v", err)
+			}
+			if g != nil && !resumeMatches(g, mode, lvl) {
+				g = nil
+			}
+		}
+		if g == nil {
+			g = newGame(mode, time.Now().UnixNano(), lvl)
+		}
+		g.savePath = *savePath
+		g.cornerWindow = *corner
+		g.player.CornerWindow = g.cornerTiles()
+		g.collision = collisionRule
+		if *debug {
+			g.rewind = newRewindBuffer(rewindSeconds)
+			g.rewind.push(g.snapshot())
+		}
+	}
+	mixer := sound.NewMixer()
+	mixer.SetMaster(*volume)
+	mixer.SetMusic(*musicVolume)
+	mixer.SetSFX(*sfxVolume)
+	mixer.SetMuted(*mute)
+	g.attachSound(mixer)
+	if *eventLog != "" {
+		w, err := openEventLog(*eventLog)
+		if err != nil {
+			panic(err)
+		}
+		defer w.Close()
+		g.logEvents(w)
+	}
+	ebiten.SetWindowClosingHandled(true)
+	if *broadcastAddr != "" {
+		server := broadcast.NewServer()
+		g.broadcast = newBroadcaster(server)
+		go func() {
+			log.Printf("broadcasting on %s%s", *broadcastAddr, broadcast.Path)
+			if err := server.ListenAndServe(*broadcastAddr); err != nil {
+				log.Printf("broadcast stopped: %v", err)
+			}
+		}()
+	}
+	w, h := g.Layout(0, 0)
+	ebiten.SetWindowSize(w*2, h*2)
 	ebiten.SetWindowTitle("Koro Game")
 
 	if err := ebiten.RunGame(g); err != nil {
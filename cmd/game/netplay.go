@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+
+	"github.com/hajimehoshi/ebiten/v2"
+
+	"github.com/sky0621/koro/internal/event"
+	"github.com/sky0621/koro/internal/koro"
+	"github.com/sky0621/koro/internal/netplay"
+)
+
+// netplayStallLimit is how long (in frames) we wait for the peer's input before giving up.
+const netplayStallLimit = 300
+
+// netplayState tracks an online match: the host steers Koro, the guest steers
+// the rival ghost. Without rollback the peers run in plain lockstep.
+type netplayState struct {
+	session  *netplay.Session
+	rollback *netplay.Rollback
+	nextSend uint32
+	stall    int
+	err      error
+	// latched holds ability presses until an input carrying them is sent;
+	// a press only lasts a frame and would be lost on one spent stalled.
+	latched uint8
+}
+
+func newNetplayState(g *Game, s *netplay.Session, rollback bool) *netplayState {
+	n := &netplayState{
+		session:  s,
+		nextSend: uint32(s.InputDelay()),
+	}
+	// Online, each player has a screen of their own to follow them on.
+	g.camera = newCamera(g.level, false)
+	if rollback {
+		n.rollback = netplay.NewRollback(s, rollbackSim{g: g})
+		g.heldEvents = map[uint32][]event.Event{}
+		g.eventFrame = g.frame
+	}
+	return n
+}
+
+// rollbackSim exposes the game to the rollback session.
+type rollbackSim struct {
+	g *Game
+}
+
+func (s rollbackSim) Step(host, guest netplay.Input) {
+	s.g.step(netFrameInput(host, guest))
+}
+
+func (s rollbackSim) Save() any {
+	return s.g.snapshot()
+}
+
+func (s rollbackSim) Load(state any) {
+	s.g.restore(state.(gameSnapshot))
+}
+
+func (s rollbackSim) Hash() uint32 {
+	return s.g.stateHash()
+}
+
+func netFrameInput(host, guest netplay.Input) frameInput {
+	return frameInput{
+		player: koro.Direction(host.Dir),
+		rival:  koro.Direction(guest.Dir),
+		start:  (host.Buttons|guest.Buttons)&netplay.ButtonStart != 0,
+		dash:   host.Buttons&netplay.ButtonDash != 0,
+		pulse:  host.Buttons&netplay.ButtonPulse != 0,
+	}
+}
+
+func (g *Game) updateNetplay() error {
+	n := g.net
+	if n.err != nil {
+		if ebiten.IsKeyPressed(ebiten.KeyEnter) {
+			g.leaveNetplay()
+		}
+		return nil
+	}
+	if err := n.session.Err(); err != nil {
+		g.endNetplay(err)
+		return nil
+	}
+
+	local := g.localNetInput()
+	if n.rollback != nil {
+		g.advanceRollback(local)
+		return nil
+	}
+	for n.nextSend <= g.frame+uint32(n.session.InputDelay()) {
+		if err := n.session.SendInput(n.nextSend, local); err != nil {
+			g.endNetplay(netplay.ErrDisconnected)
+			return nil
+		}
+		n.nextSend++
+		n.latched = 0
+	}
+
+	host, guest, ok := n.session.Inputs(g.frame)
+	if !ok {
+		n.stall++
+		if n.stall > netplayStallLimit {
+			g.endNetplay(netplay.ErrDisconnected)
+		}
+		return nil
+	}
+	n.stall = 0
+
+	// Hashes describe the state at the start of a frame, matching rollback peers.
+	if g.frame%netplay.HashInterval == 0 {
+		if err := n.session.SendHash(g.frame, g.stateHash()); err != nil {
+			g.endNetplay(netplay.ErrDisconnected)
+			return nil
+		}
+	}
+	g.step(netFrameInput(host, guest))
+	return nil
+}
+
+func (g *Game) advanceRollback(local netplay.Input) {
+	n := g.net
+	scheduled := n.rollback.NextInputFrame()
+	advanced := n.rollback.Advance(local)
+	if n.rollback.NextInputFrame() != scheduled {
+		n.latched = 0
+	}
+	// Simulated frame f leaves the game on frame f+1, so the frames before
+	// the confirmed one end on game frames up to it.
+	g.publishConfirmed(n.rollback.Confirmed())
+	if advanced {
+		n.stall = 0
+		return
+	}
+	n.stall++
+	if n.stall > netplayStallLimit {
+		g.endNetplay(netplay.ErrDisconnected)
+	}
+}
+
+func (g *Game) localNetInput() netplay.Input {
+	g.input.Update()
+	in := netplay.Input{Dir: uint8(g.input.Direction())}
+	if ebiten.IsKeyPressed(ebiten.KeyEnter) {
+		in.Buttons |= netplay.ButtonStart
+	}
+	if g.input.Action(koro.AbilityDash) {
+		in.Buttons |= netplay.ButtonDash
+	}
+	if g.input.Action(koro.AbilityPulse) {
+		in.Buttons |= netplay.ButtonPulse
+	}
+	g.net.latched |= in.Buttons & (netplay.ButtonDash | netplay.ButtonPulse)
+	in.Buttons |= g.net.latched
+	return in
+}
+
+func (g *Game) endNetplay(err error) {
+	g.net.err = err
+	g.net.session.Close()
+}
+
+// leaveNetplay keeps the current match going offline with the AI taking the rival ghost back.
+func (g *Game) leaveNetplay() {
+	g.net = nil
+	g.mode = ModeClassic
+	g.rivalInput = nil
+	g.rival().SetPlayerControlled(false)
+	// Offline, the frames on screen are final.
+	if g.heldEvents != nil {
+		g.publishConfirmed(g.frame)
+		g.heldEvents = nil
+	}
+}
+
+func (g *Game) netplayStatus() string {
+	n := g.net
+	switch {
+	case errors.Is(n.err, netplay.ErrDesync):
+		return "\nDESYNC - Enter: continue offline"
+	case n.err != nil:
+		return "\nDISCONNECTED - Enter: continue offline"
+	case n.stall > 0:
+		return "  Waiting for peer..."
+	case n.session.Role() == netplay.RoleHost:
+		return "  Online: you are Koro"
+	default:
+		return "  Online: you are the red ghost"
+	}
+}
+
+// stateHash summarises the simulation so peers can detect divergence.
+func (g *Game) stateHash() uint32 {
+	h := fnv.New32a()
+	var buf [8]byte
+	putInt := func(v int64) {
+		binary.LittleEndian.PutUint64(buf[:], uint64(v))
+		h.Write(buf[:])
+	}
+
+	putInt(int64(g.frame))
+	putInt(int64(g.state))
+	putInt(int64(g.score))
+	putInt(int64(g.lives))
+	putInt(int64(g.powerTimer))
+	putInt(int64(g.level.RemainingPellets()))
+	x, y := g.player.Pos()
+	putInt(int64(x))
+	putInt(int64(y))
+	for _, gh := range g.ghosts {
+		x, y := gh.Body().Pos()
+		putInt(int64(x))
+		putInt(int64(y))
+	}
+	return h.Sum32()
+}
@@ -0,0 +1,64 @@
+package main
+
+import "github.com/sky0621/koro/internal/level"
+
+const alternatePlayers = 2
+
+// playerSlot holds the progress of a player who is waiting for their turn.
+type playerSlot struct {
+	score       int
+	lives       int
+	levelNumber int
+	pellets     level.PelletState
+	started     bool
+}
+
+func (g *Game) resetPlayers(count int) {
+	g.players = make([]playerSlot, count)
+	for i := range g.players {
+		g.players[i] = playerSlot{lives: startLives, levelNumber: 1}
+	}
+	g.activePlayer = 0
+	g.players[0].started = true
+}
+
+// nextPlayerTurn parks the active player's board and hands control to the next
+// player that still has lives. It reports false when play should continue as in
+// single-player mode: either nobody else is left or the active player is alone.
+func (g *Game) nextPlayerTurn() bool {
+	g.players[g.activePlayer] = playerSlot{
+		score:       g.score,
+		lives:       g.lives,
+		levelNumber: g.levelNumber,
+		pellets:     g.level.SnapshotPellets(),
+		started:     true,
+	}
+
+	next := -1
+	for i := 1; i <= len(g.players); i++ {
+		idx := (g.activePlayer + i) % len(g.players)
+		if g.players[idx].lives > 0 {
+			next = idx
+			break
+		}
+	}
+	if next < 0 || next == g.activePlayer {
+		return false
+	}
+
+	g.activePlayer = next
+	slot := g.players[next]
+	g.score = slot.score
+	g.lives = slot.lives
+	g.levelNumber = slot.levelNumber
+	g.level = g.freshLevel()
+	if slot.started {
+		g.level.RestorePellets(slot.pellets)
+	}
+	g.players[next].started = true
+	g.walkable = g.level.WalkableTiles()
+	g.setupActors()
+	g.state = StateReady
+	g.readyTimer = playerSwapDelayFrames
+	return true
+}
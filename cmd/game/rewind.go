@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+
+	"github.com/sky0621/koro/internal/koro"
+	"github.com/sky0621/koro/internal/render"
+)
+
+const (
+	rewindSeconds = 10
+	// rewindRepeatDelay is how long a step key must be held before it auto-repeats.
+	rewindRepeatDelay = 15
+)
+
+var (
+	colorDebugTarget = color.RGBA{255, 255, 255, 140}
+	colorDebugChoice = color.RGBA{0, 255, 0, 220}
+)
+
+// rewindBuffer keeps the most recent frames of a debug session so they can be
+// stepped through backwards and forwards.
+type rewindBuffer struct {
+	frames []gameSnapshot
+	start  int
+	count  int
+	cursor int
+	active bool
+}
+
+func newRewindBuffer(seconds int) *rewindBuffer {
+	return &rewindBuffer{frames: make([]gameSnapshot, seconds*ebiten.DefaultTPS)}
+}
+
+func (r *rewindBuffer) push(s gameSnapshot) {
+	idx := (r.start + r.count) % len(r.frames)
+	r.frames[idx] = s
+	if r.count < len(r.frames) {
+		r.count++
+	} else {
+		r.start = (r.start + 1) % len(r.frames)
+	}
+	r.cursor = r.count - 1
+}
+
+func (r *rewindBuffer) at(i int) gameSnapshot {
+	return r.frames[(r.start+i)%len(r.frames)]
+}
+
+// truncate forgets every frame after the cursor, so resuming from the past
+// starts a new timeline.
+func (r *rewindBuffer) truncate() {
+	r.count = r.cursor + 1
+}
+
+// updateRewind handles the time-travel hotkeys: F1 freezes or resumes play,
+// comma steps back and period steps forward. It reports whether it consumed the frame.
+func (g *Game) updateRewind() bool {
+	r := g.rewind
+	if inpututil.IsKeyJustPressed(ebiten.KeyF1) {
+		r.active = !r.active
+		if !r.active {
+			r.truncate()
+		}
+	}
+	if !r.active {
+		return false
+	}
+
+	switch {
+	case keyStepped(ebiten.KeyComma) && r.cursor > 0:
+		r.cursor--
+		g.restore(r.at(r.cursor))
+	case keyStepped(ebiten.KeyPeriod):
+		if r.cursor < r.count-1 {
+			r.cursor++
+			g.restore(r.at(r.cursor))
+		} else {
+			g.step(g.pollInput())
+			r.push(g.snapshot())
+		}
+	}
+	return true
+}
+
+func keyStepped(key ebiten.Key) bool {
+	d := inpututil.KeyPressDuration(key)
+	return d == 1 || d > rewindRepeatDelay
+}
+
+func (g *Game) rewindStatus() string {
+	r := g.rewind
+	if !r.active {
+		return "\nDEBUG - F1: rewind"
+	}
+	return fmt.Sprintf("\nREWIND frame %d (%d/%d)  ,/. step  F1 resume", g.frame, r.cursor+1, r.count)
+}
+
+// drawDebugOverlay shows where each ghost is heading and which way it turned.
+func (g *Game) drawDebugOverlay(screen *ebiten.Image, v view) {
+	for _, gh := range g.ghosts {
+		cx, cy := gh.Body().PixelCenter()
+		target, dir := gh.Decision()
+		tx := (float64(target.Col)+0.5)*g.tileSize - v.x
+		ty := (float64(target.Row)+0.5)*g.tileSize - v.y
+		cx, cy = cx-v.x, cy-v.y
+		render.StrokeLine(screen, cx, cy, tx, ty, 1, colorDebugTarget)
+		render.FillRect(screen, tx-2, ty-2, 4, 4, gh.Color())
+		if dir == koro.DirNone {
+			continue
+		}
+		dx, dy := dir.Delta()
+		reach := gh.Size() * 0.8
+		tipX := cx + float64(dx)*reach
+		tipY := cy + float64(dy)*reach
+		// Base of the arrow is perpendicular to the chosen direction.
+		px, py := float64(-dy)*gh.Size()*0.2, float64(dx)*gh.Size()*0.2
+		baseX := cx + float64(dx)*reach*0.5
+		baseY := cy + float64(dy)*reach*0.5
+		render.FillTriangle(screen, tipX, tipY, baseX+px, baseY+py, baseX-px, baseY-py, colorDebugChoice)
+	}
+}
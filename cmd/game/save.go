@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/sky0621/koro/internal/ghost"
+	"github.com/sky0621/koro/internal/koro"
+	"github.com/sky0621/koro/internal/level"
+)
+
+// saveVersion is bumped whenever saveFile changes incompatibly.
+const saveVersion = 3
+
+// saveFile is the on-disk form of an in-progress game. Pellets are stored as
+// the positions eaten so far, relative to the level's original layout, and
+// doors, keys and gates as the positions whose state has changed.
+type saveFile struct {
+	Version      int             `json:"version"`
+	Mode         string          `json:"mode"`
+	LevelID      string          `json:"level_id"`
+	Consumed     []level.GridPos `json:"consumed"`
+	Flipped      []level.GridPos `json:"flipped,omitempty"`
+	Frame        uint32          `json:"frame"`
+	Score        int             `json:"score"`
+	Lives        int             `json:"lives"`
+	LevelNumber  int             `json:"level_number"`
+	State        GameState       `json:"state"`
+	ReadyTimer   int             `json:"ready_timer"`
+	PowerTimer   int             `json:"power_timer"`
+	Effects      effectTimers    `json:"effects"`
+	RNG          uint64          `json:"rng"`
+	Player       koro.State      `json:"player"`
+	Ghosts       []ghost.State   `json:"ghosts"`
+	Players      []savedPlayer   `json:"players,omitempty"`
+	ActivePlayer int             `json:"active_player"`
+}
+
+// savedPlayer is a waiting player's slot in alternate mode.
+type savedPlayer struct {
+	Score       int             `json:"score"`
+	Lives       int             `json:"lives"`
+	LevelNumber int             `json:"level_number"`
+	Consumed    []level.GridPos `json:"consumed"`
+	Flipped     []level.GridPos `json:"flipped,omitempty"`
+	Started     bool            `json:"started"`
+}
+
+// defaultSavePath returns where the autosave lives when -save is not given.
+func defaultSavePath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "koro-save.json"
+	}
+	return filepath.Join(dir, "koro", "save.json")
+}
+
+// autosave writes the current game to disk, or removes a stale save once the game is over.
+func (g *Game) autosave() {
+	if g.savePath == "" || g.net != nil {
+		return
+	}
+	if g.state == StateGameOver {
+		if err := os.Remove(g.savePath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("remove save: %v", err)
+		}
+		return
+	}
+	if err := g.writeSave(g.savePath); err != nil {
+		log.Printf("autosave: %v", err)
+	}
+}
+
+func (g *Game) writeSave(path string) error {
+	data, err := json.MarshalIndent(g.saveFile(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// Write then rename so a crash mid-save never leaves a truncated file.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (g *Game) saveFile() saveFile {
+	snap := g.snapshot()
+	s := saveFile{
+		Version:      saveVersion,
+		Mode:         g.mode.String(),
+		LevelID:      g.level.ID,
+		Consumed:     g.consumedPellets(snap.pellets),
+		Flipped:      snap.pellets.ChangedFeatures(),
+		Frame:        snap.frame,
+		Score:        snap.score,
+		Lives:        snap.lives,
+		LevelNumber:  snap.levelNumber,
+		State:        snap.state,
+		ReadyTimer:   snap.readyTimer,
+		PowerTimer:   snap.powerTimer,
+		Effects:      snap.effects,
+		RNG:          snap.rng,
+		Player:       snap.player,
+		Ghosts:       snap.ghosts,
+		ActivePlayer: snap.activePlayer,
+	}
+	for _, p := range snap.players {
+		s.Players = append(s.Players, savedPlayer{
+			Score:       p.score,
+			Lives:       p.lives,
+			LevelNumber: p.levelNumber,
+			Consumed:    g.consumedPellets(p.pellets),
+			Flipped:     p.pellets.ChangedFeatures(),
+			Started:     p.started,
+		})
+	}
+	return s
+}
+
+// consumedPellets lists the positions that have a pellet in the level's
+// original layout but not in state.
+func (g *Game) consumedPellets(state level.PelletState) []level.GridPos {
+	fresh := g.freshLevel()
+	eaten := g.freshLevel()
+	eaten.RestorePellets(state)
+	consumed := []level.GridPos{}
+	for row := 0; row < fresh.Height; row++ {
+		for col := 0; col < fresh.Width; col++ {
+			if fresh.PelletAt(col, row) != level.PelletNone && eaten.PelletAt(col, row) == level.PelletNone {
+				consumed = append(consumed, level.GridPos{Col: col, Row: row})
+			}
+		}
+	}
+	return consumed
+}
+
+// loadGame rebuilds a game from a save file written by writeSave.
+func loadGame(path string) (*Game, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s saveFile
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("parse save: %w", err)
+	}
+	if s.Version != saveVersion {
+		return nil, fmt.Errorf("unsupported save version %d", s.Version)
+	}
+	mode, err := parseMode(s.Mode)
+	if err != nil {
+		return nil, err
+	}
+	pellets, err := pelletsWithout(s.LevelID, s.Consumed, s.Flipped)
+	if err != nil {
+		return nil, err
+	}
+
+	lvl, err := level.ByID(s.LevelID)
+	if err != nil {
+		return nil, err
+	}
+	g := newGame(mode, 0, lvl)
+	if len(s.Ghosts) != len(g.ghosts) {
+		return nil, fmt.Errorf("save has %d ghosts, game has %d", len(s.Ghosts), len(g.ghosts))
+	}
+
+	snap := gameSnapshot{
+		frame:        s.Frame,
+		score:        s.Score,
+		lives:        s.Lives,
+		levelNumber:  s.LevelNumber,
+		state:        s.State,
+		readyTimer:   s.ReadyTimer,
+		powerTimer:   s.PowerTimer,
+		effects:      s.Effects,
+		rng:          s.RNG,
+		pellets:      pellets,
+		player:       s.Player,
+		ghosts:       s.Ghosts,
+		activePlayer: s.ActivePlayer,
+	}
+	for _, p := range s.Players {
+		slotPellets, err := pelletsWithout(s.LevelID, p.Consumed, p.Flipped)
+		if err != nil {
+			return nil, err
+		}
+		snap.players = append(snap.players, playerSlot{
+			score:       p.Score,
+			lives:       p.Lives,
+			levelNumber: p.LevelNumber,
+			pellets:     slotPellets,
+			started:     p.Started,
+		})
+	}
+	g.restore(snap)
+	// The save does not record the last tile, so a switch under the player
+	// must not fire again on the first frame.
+	g.playerTile = g.player.Tile()
+	// Give the player a moment to get their bearings before play continues.
+	g.paused = true
+	return g, nil
+}
+
+// pelletsWithout rebuilds a level's state from the changes a save records.
+func pelletsWithout(levelID string, consumed, flipped []level.GridPos) (level.PelletState, error) {
+	lvl, err := level.ByID(levelID)
+	if err != nil {
+		return level.PelletState{}, err
+	}
+	for _, pos := range consumed {
+		lvl.ConsumePellet(pos.Col, pos.Row)
+	}
+	lvl.SetChangedFeatures(flipped)
+	return lvl.SnapshotPellets(), nil
+}
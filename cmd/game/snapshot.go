@@ -0,0 +1,74 @@
+package main
+
+import (
+	"github.com/sky0621/koro/internal/ghost"
+	"github.com/sky0621/koro/internal/koro"
+	"github.com/sky0621/koro/internal/level"
+)
+
+// gameSnapshot is a full copy of the simulation state. Restoring one and
+// replaying the same inputs reproduces the same frames.
+type gameSnapshot struct {
+	frame        uint32
+	score        int
+	lives        int
+	levelNumber  int
+	state        GameState
+	readyTimer   int
+	powerTimer   int
+	effects      effectTimers
+	playerTile   level.GridPos
+	rng          uint64
+	pellets      level.PelletState
+	player       koro.State
+	ghosts       []ghost.State
+	players      []playerSlot
+	activePlayer int
+}
+
+func (g *Game) snapshot() gameSnapshot {
+	s := gameSnapshot{
+		frame:        g.frame,
+		score:        g.score,
+		lives:        g.lives,
+		levelNumber:  g.levelNumber,
+		state:        g.state,
+		readyTimer:   g.readyTimer,
+		powerTimer:   g.powerTimer,
+		effects:      g.effects,
+		playerTile:   g.playerTile,
+		rng:          g.rngSource.State(),
+		pellets:      g.level.SnapshotPellets(),
+		player:       g.player.Snapshot(),
+		ghosts:       make([]ghost.State, len(g.ghosts)),
+		players:      append([]playerSlot(nil), g.players...),
+		activePlayer: g.activePlayer,
+	}
+	for i, gh := range g.ghosts {
+		s.ghosts[i] = gh.Snapshot()
+	}
+	return s
+}
+
+func (g *Game) restore(s gameSnapshot) {
+	g.frame = s.frame
+	g.score = s.score
+	g.lives = s.lives
+	g.levelNumber = s.levelNumber
+	g.state = s.state
+	g.readyTimer = s.readyTimer
+	g.powerTimer = s.powerTimer
+	g.effects = s.effects
+	g.playerTile = s.playerTile
+	g.rngSource.SetState(s.rng)
+	g.level.RestorePellets(s.pellets)
+	g.walkable = g.level.WalkableTiles()
+	g.player.Restore(s.player)
+	for i, gh := range g.ghosts {
+		if i < len(s.ghosts) {
+			gh.Restore(s.ghosts[i])
+		}
+	}
+	g.players = append([]playerSlot(nil), s.players...)
+	g.activePlayer = s.activePlayer
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+
+	"github.com/sky0621/koro/internal/event"
+	"github.com/sky0621/koro/internal/sound"
+)
+
+// attachSound plays effects for gameplay events on m.
+func (g *Game) attachSound(m *sound.Mixer) {
+	g.sound = m
+	event.On(g.events, func(event.PelletEaten) { m.Play(sound.Waka) })
+	event.On(g.events, func(event.PowerStarted) { m.Play(sound.Power) })
+	event.On(g.events, func(event.GhostEaten) { m.Play(sound.GhostEaten) })
+	event.On(g.events, func(event.PlayerDied) { m.Play(sound.Death) })
+}
+
+// updateSound toggles mute on M and keeps the music in step with play. The
+// music follows the game state rather than events so that it comes out
+// right after loading a save, a rollback or a rewind.
+func (g *Game) updateSound() {
+	if inpututil.IsKeyJustPressed(ebiten.KeyM) {
+		g.sound.SetMuted(!g.sound.Muted())
+	}
+	g.sound.Suspend(g.paused || (g.rewind != nil && g.rewind.active))
+	switch {
+	case g.state != StatePlaying:
+		g.sound.PlayMusic(sound.NoTrack)
+	case g.powerTimer > 0:
+		g.sound.PlayMusic(sound.Frightened)
+	default:
+		g.sound.PlayMusic(sound.Siren)
+	}
+}
+
+func (g *Game) soundStatus() string {
+	if g.sound != nil && g.sound.Muted() {
+		return "  MUTED"
+	}
+	return ""
+}
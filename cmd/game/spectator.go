@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+
+	"github.com/sky0621/koro/internal/broadcast"
+	"github.com/sky0621/koro/internal/ghost"
+	"github.com/sky0621/koro/internal/koro"
+	"github.com/sky0621/koro/internal/level"
+	"github.com/sky0621/koro/internal/render"
+)
+
+const (
+	spectatorWaitWidth  = 320
+	spectatorWaitHeight = 240
+)
+
+// Spectator is a read-only view that renders a broadcast stream.
+type Spectator struct {
+	client *broadcast.Client
+	level  *level.Level
+	frame  *broadcast.Frame
+	ended  bool
+}
+
+func newSpectator(c *broadcast.Client) *Spectator {
+	return &Spectator{client: c}
+}
+
+func (s *Spectator) Update() error {
+	for !s.ended {
+		select {
+		case f, ok := <-s.client.Frames():
+			if !ok {
+				s.ended = true
+				return nil
+			}
+			s.apply(f)
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func (s *Spectator) apply(f *broadcast.Frame) {
+	if k := f.Keyframe; k != nil {
+		lvl, err := level.New(k.Layout, k.TileSize)
+		if err != nil {
+			log.Printf("spectate: bad keyframe: %v", err)
+			return
+		}
+		for i, p := range k.Pellets {
+			lvl.SetPellet(i%lvl.Width, i/lvl.Width, p)
+		}
+		if s.level == nil {
+			ebiten.SetWindowSize(lvl.PixelWidth()*2, lvl.PixelHeight()*2)
+		}
+		s.level = lvl
+	}
+	if s.level == nil {
+		// Deltas are meaningless until the first keyframe arrives.
+		return
+	}
+	for _, c := range f.Changes {
+		s.level.SetPellet(c.Pos.Col, c.Pos.Row, c.Pellet)
+	}
+	s.frame = f
+}
+
+func (s *Spectator) Draw(screen *ebiten.Image) {
+	if s.level == nil || s.frame == nil {
+		msg := "Waiting for broadcast..."
+		if s.ended {
+			msg = fmt.Sprintf("Broadcast ended: %v", s.client.Err())
+		}
+		ebitenutil.DebugPrint(screen, msg)
+		return
+	}
+
+	drawLevel(screen, s.level, fullView(s.level))
+	drawFeatures(screen, s.level, fullView(s.level))
+	drawPellets(screen, s.level, fullView(s.level))
+	size := float64(s.level.TileSize)
+	for i, a := range s.frame.Ghosts {
+		clr := ghostColors[i%len(ghostColors)]
+		if a.Frightened {
+			clr = ghost.FrightenedColor
+		}
+		render.DrawGhost(screen, float64(a.X), float64(a.Y), size, clr, a.Frightened)
+	}
+	p := s.frame.Player
+	render.DrawPlayer(screen, float64(p.X), float64(p.Y), size, koro.Direction(p.Dir), colorPlayer, colorFloor)
+
+	text := fmt.Sprintf("SPECTATING  Score: %d  Lives: %d  Level: %d", s.frame.Score, s.frame.Lives, s.frame.Level)
+	switch GameState(s.frame.State) {
+	case StateCleared:
+		text += "  LEVEL CLEAR"
+	case StateGameOver:
+		text += "  GAME OVER"
+	}
+	if s.ended {
+		text += "\nBroadcast ended"
+	}
+	ebitenutil.DebugPrint(screen, text)
+}
+
+func (s *Spectator) Layout(outsideWidth, outsideHeight int) (int, int) {
+	if s.level == nil {
+		return spectatorWaitWidth, spectatorWaitHeight
+	}
+	return s.level.PixelWidth(), s.level.PixelHeight()
+}
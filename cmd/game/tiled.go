@@ -0,0 +1,69 @@
+package main
+
+import (
+	"image"
+	_ "image/png"
+	"log"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+
+	"github.com/sky0621/koro/internal/level"
+)
+
+// tileArt draws a level with the tilesets of the Tiled map it was imported from.
+type tileArt struct {
+	m     *level.TiledMap
+	tiles map[uint32]*ebiten.Image
+}
+
+// newTileArt loads the tileset images of an imported level. It returns nil for
+// text layouts, or when an image is missing so the flat colours take over.
+func newTileArt(lvl *level.Level) *tileArt {
+	m := lvl.Tiled()
+	if m == nil {
+		return nil
+	}
+	a := &tileArt{m: m, tiles: map[uint32]*ebiten.Image{}}
+	for _, ts := range m.Tilesets {
+		if ts.Image == "" {
+			continue
+		}
+		img, _, err := ebitenutil.NewImageFromFile(ts.Image)
+		if err != nil {
+			log.Printf("tileset %s: %v", ts.Image, err)
+			return nil
+		}
+		for i := 0; i < ts.TileCount; i++ {
+			gid := ts.FirstGID + uint32(i)
+			x, y, ok := ts.Source(gid)
+			if !ok {
+				continue
+			}
+			rect := image.Rect(x, y, x+ts.TileWidth, y+ts.TileHeight)
+			a.tiles[gid] = img.SubImage(rect).(*ebiten.Image)
+		}
+	}
+	return a
+}
+
+// draw paints every layer's tiles inside v in order, scaled to the level's tile size.
+func (a *tileArt) draw(screen *ebiten.Image, lvl *level.Level, v view) {
+	size := float64(lvl.TileSize)
+	col0, row0, col1, row1 := v.tiles(lvl)
+	for _, layer := range a.m.Layers {
+		for row := row0; row < row1; row++ {
+			for col := col0; col < col1; col++ {
+				tile, ok := a.tiles[layer[row*a.m.Width+col]]
+				if !ok {
+					continue
+				}
+				op := &ebiten.DrawImageOptions{}
+				bounds := tile.Bounds()
+				op.GeoM.Scale(size/float64(bounds.Dx()), size/float64(bounds.Dy()))
+				op.GeoM.Translate(float64(col)*size-v.x, float64(row)*size-v.y)
+				screen.DrawImage(tile, op)
+			}
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/sky0621/koro/internal/level"
+)
+
+// finding is one issue tagged with the file it came from.
+type finding struct {
+	File string `json:"file"`
+	level.Issue
+}
+
+func main() {
+	asJSON := flag.Bool("json", false, "print findings as a JSON array")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: korolint [-json] layout-file...\n\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "Rows and columns are zero-based, matching level.GridPos; -1 means the whole level.\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	findings := []finding{}
+	failed := false
+	for _, path := range flag.Args() {
+		layout, err := readLayout(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "korolint: %v\n", err)
+			os.Exit(2)
+		}
+		for _, issue := range level.Validate(layout) {
+			findings = append(findings, finding{File: path, Issue: issue})
+			if issue.Severity == level.SeverityError {
+				failed = true
+			}
+		}
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(findings); err != nil {
+			fmt.Fprintf(os.Stderr, "korolint: %v\n", err)
+			os.Exit(2)
+		}
+	} else {
+		for _, f := range findings {
+			fmt.Printf("%s:%d:%d: %s: %s [%s]\n", f.File, f.Row, f.Col, f.Severity, f.Message, f.Code)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// readLayout reads a text layout, or converts a Tiled map to one.
+func readLayout(path string) ([]string, error) {
+	if !level.IsTiledFile(path) {
+		return level.ReadLayoutFile(path)
+	}
+	m, err := level.ImportTiled(path)
+	if err != nil {
+		return nil, err
+	}
+	return m.Layout, nil
+}
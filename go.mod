@@ -2,11 +2,15 @@ module github.com/sky0621/koro
 
 go 1.25.0
 
-require github.com/hajimehoshi/ebiten/v2 v2.9.4
+require (
+	github.com/hajimehoshi/ebiten/v2 v2.9.4
+	golang.org/x/net v0.44.0
+)
 
 require (
 	github.com/ebitengine/gomobile v0.0.0-20250923094054-ea854a63cce1 // indirect
 	github.com/ebitengine/hideconsole v1.0.0 // indirect
+	github.com/ebitengine/oto/v3 v3.4.0 // indirect
 	github.com/ebitengine/purego v0.9.0 // indirect
 	github.com/jezek/xgb v1.1.1 // indirect
 	golang.org/x/sync v0.17.0 // indirect
@@ -0,0 +1,73 @@
+package broadcast
+
+import (
+	"fmt"
+	"sync"
+
+	"golang.org/x/net/websocket"
+)
+
+// Client receives the frame stream of a broadcasting game.
+type Client struct {
+	ws     *websocket.Conn
+	frames chan *Frame
+
+	mu  sync.Mutex
+	err error
+}
+
+// Dial connects to a broadcast URL such as ws://localhost:8080/spectate.
+func Dial(url string) (*Client, error) {
+	ws, err := websocket.Dial(url, "", "http://localhost/")
+	if err != nil {
+		return nil, fmt.Errorf("connect to %s: %w", url, err)
+	}
+	c := &Client{
+		ws:     ws,
+		frames: make(chan *Frame, clientBuffer),
+	}
+	go c.readLoop()
+	return c, nil
+}
+
+// Frames delivers decoded frames in order; it is closed when the stream ends.
+func (c *Client) Frames() <-chan *Frame {
+	return c.frames
+}
+
+// Err returns why the stream ended, if it has.
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Close disconnects from the broadcaster.
+func (c *Client) Close() error {
+	return c.ws.Close()
+}
+
+func (c *Client) readLoop() {
+	defer close(c.frames)
+	for {
+		var data []byte
+		if err := websocket.Message.Receive(c.ws, &data); err != nil {
+			c.setErr(err)
+			return
+		}
+		f, err := Decode(data)
+		if err != nil {
+			c.setErr(err)
+			return
+		}
+		c.frames <- f
+	}
+}
+
+func (c *Client) setErr(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		c.err = err
+	}
+}
@@ -0,0 +1,221 @@
+package broadcast
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+
+	"github.com/sky0621/koro/internal/level"
+)
+
+const frameVersion = 1
+
+var errShortFrame = errors.New("broadcast: truncated frame")
+
+// Actor is the drawable state of Koro or a ghost.
+type Actor struct {
+	X, Y       float32
+	Dir        uint8
+	Frightened bool
+}
+
+// PelletChange records that the pellet at Pos is now Pellet.
+type PelletChange struct {
+	Pos    level.GridPos
+	Pellet level.PelletType
+}
+
+// Keyframe carries everything a spectator needs to rebuild the board from scratch.
+type Keyframe struct {
+	Layout   []string
+	TileSize int
+	// Pellets holds the current pellet grid in row-major order.
+	Pellets []level.PelletType
+}
+
+// Frame is the compact per-frame state streamed to spectators.
+type Frame struct {
+	Frame      uint32
+	Score      int
+	Lives      int
+	Level      int
+	State      int
+	PowerTimer int
+	Player     Actor
+	Ghosts     []Actor
+	Changes    []PelletChange
+	// Keyframe is set on the first frame a spectator sees and after dropped frames.
+	Keyframe *Keyframe
+}
+
+// Encode serialises the frame for the wire.
+func (f *Frame) Encode() []byte {
+	buf := make([]byte, 0, 64+len(f.Ghosts)*10+len(f.Changes)*3)
+	flags := byte(0)
+	if f.Keyframe != nil {
+		flags |= 1
+	}
+	buf = append(buf, frameVersion, flags)
+	buf = binary.AppendUvarint(buf, uint64(f.Frame))
+	for _, v := range []int{f.Score, f.Lives, f.Level, f.State, f.PowerTimer} {
+		buf = binary.AppendVarint(buf, int64(v))
+	}
+	buf = appendActor(buf, f.Player)
+	buf = binary.AppendUvarint(buf, uint64(len(f.Ghosts)))
+	for _, a := range f.Ghosts {
+		buf = appendActor(buf, a)
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(f.Changes)))
+	for _, c := range f.Changes {
+		buf = binary.AppendUvarint(buf, uint64(c.Pos.Col))
+		buf = binary.AppendUvarint(buf, uint64(c.Pos.Row))
+		buf = append(buf, byte(c.Pellet))
+	}
+	if k := f.Keyframe; k != nil {
+		buf = binary.AppendUvarint(buf, uint64(k.TileSize))
+		buf = binary.AppendUvarint(buf, uint64(len(k.Layout)))
+		for _, row := range k.Layout {
+			buf = binary.AppendUvarint(buf, uint64(len(row)))
+			buf = append(buf, row...)
+		}
+		buf = binary.AppendUvarint(buf, uint64(len(k.Pellets)))
+		for _, p := range k.Pellets {
+			buf = append(buf, byte(p))
+		}
+	}
+	return buf
+}
+
+// Decode parses a frame produced by Encode.
+func Decode(data []byte) (*Frame, error) {
+	if len(data) == 0 {
+		return nil, errShortFrame
+	}
+	r := &reader{data: data}
+	if r.byte() != frameVersion {
+		return nil, errors.New("broadcast: unsupported frame version")
+	}
+	flags := r.byte()
+	f := &Frame{Frame: uint32(r.uvarint())}
+	for _, v := range []*int{&f.Score, &f.Lives, &f.Level, &f.State, &f.PowerTimer} {
+		*v = int(r.varint())
+	}
+	f.Player = r.actor()
+	f.Ghosts = make([]Actor, r.count())
+	for i := range f.Ghosts {
+		f.Ghosts[i] = r.actor()
+	}
+	f.Changes = make([]PelletChange, r.count())
+	for i := range f.Changes {
+		col := int(r.uvarint())
+		row := int(r.uvarint())
+		f.Changes[i] = PelletChange{
+			Pos:    level.GridPos{Col: col, Row: row},
+			Pellet: level.PelletType(r.byte()),
+		}
+	}
+	if flags&1 != 0 {
+		k := &Keyframe{TileSize: int(r.uvarint())}
+		k.Layout = make([]string, r.count())
+		for i := range k.Layout {
+			k.Layout[i] = string(r.bytes(r.count()))
+		}
+		k.Pellets = make([]level.PelletType, r.count())
+		for i := range k.Pellets {
+			k.Pellets[i] = level.PelletType(r.byte())
+		}
+		f.Keyframe = k
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return f, nil
+}
+
+func appendActor(buf []byte, a Actor) []byte {
+	buf = binary.LittleEndian.AppendUint32(buf, math.Float32bits(a.X))
+	buf = binary.LittleEndian.AppendUint32(buf, math.Float32bits(a.Y))
+	flags := byte(0)
+	if a.Frightened {
+		flags |= 1
+	}
+	return append(buf, a.Dir, flags)
+}
+
+// reader walks an encoded frame, remembering the first error instead of
+// returning one from every call.
+type reader struct {
+	data []byte
+	err  error
+}
+
+func (r *reader) fail() {
+	if r.err == nil {
+		r.err = errShortFrame
+	}
+	r.data = nil
+}
+
+func (r *reader) byte() byte {
+	if len(r.data) < 1 {
+		r.fail()
+		return 0
+	}
+	b := r.data[0]
+	r.data = r.data[1:]
+	return b
+}
+
+func (r *reader) bytes(n int) []byte {
+	if len(r.data) < n {
+		r.fail()
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *reader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.fail()
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *reader) varint() int64 {
+	v, n := binary.Varint(r.data)
+	if n <= 0 {
+		r.fail()
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+// count reads a length prefix, bounded by the bytes left so corrupt input cannot
+// trigger huge allocations.
+func (r *reader) count() int {
+	n := r.uvarint()
+	if n > uint64(len(r.data)) {
+		r.fail()
+		return 0
+	}
+	return int(n)
+}
+
+func (r *reader) actor() Actor {
+	b := r.bytes(10)
+	if b == nil {
+		return Actor{}
+	}
+	return Actor{
+		X:          math.Float32frombits(binary.LittleEndian.Uint32(b[0:4])),
+		Y:          math.Float32frombits(binary.LittleEndian.Uint32(b[4:8])),
+		Dir:        b[8],
+		Frightened: b[9]&1 != 0,
+	}
+}
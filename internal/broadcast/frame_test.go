@@ -0,0 +1,147 @@
+package broadcast
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/sky0621/koro/internal/level"
+)
+
+func sameFrame(a, b *Frame) bool {
+	if a.Frame != b.Frame || a.Score != b.Score || a.Lives != b.Lives || a.Level != b.Level ||
+		a.State != b.State || a.PowerTimer != b.PowerTimer || a.Player != b.Player ||
+		!slices.Equal(a.Ghosts, b.Ghosts) || !slices.Equal(a.Changes, b.Changes) {
+		return false
+	}
+	if a.Keyframe == nil || b.Keyframe == nil {
+		return a.Keyframe == nil && b.Keyframe == nil
+	}
+	return a.Keyframe.TileSize == b.Keyframe.TileSize &&
+		slices.Equal(a.Keyframe.Layout, b.Keyframe.Layout) &&
+		slices.Equal(a.Keyframe.Pellets, b.Keyframe.Pellets)
+}
+
+var keyframeFrame = &Frame{
+	Frame:  1,
+	Score:  10,
+	Lives:  3,
+	Level:  1,
+	Player: Actor{X: 16, Y: 16, Dir: 1},
+	Ghosts: []Actor{{X: 32, Y: 16}},
+	Keyframe: &Keyframe{
+		Layout:   []string{"#####", "#P.G#", "#####"},
+		TileSize: 16,
+		Pellets: []level.PelletType{
+			0, 0, 0, 0, 0,
+			0, 0, level.PelletSmall, 0, 0,
+			0, 0, 0, 0, 0,
+		},
+	},
+}
+
+func TestFrameRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame *Frame
+	}{
+		{"empty", &Frame{}},
+		{
+			name: "delta",
+			frame: &Frame{
+				Frame:      1 << 30,
+				Score:      123456,
+				Lives:      2,
+				Level:      7,
+				State:      1,
+				PowerTimer: 300,
+				Player:     Actor{X: 104.5, Y: -8, Dir: 3},
+				Ghosts: []Actor{
+					{X: 1, Y: 2, Dir: 1, Frightened: true},
+					{X: float32(math.Inf(1)), Y: 0.25, Dir: 4},
+				},
+				Changes: []PelletChange{
+					{Pos: level.GridPos{Col: 3, Row: 11}},
+					{Pos: level.GridPos{Col: 200, Row: 300}, Pellet: level.PelletShield},
+				},
+			},
+		},
+		{"negative counters", &Frame{Score: -5, Lives: -1, PowerTimer: math.MinInt32}},
+		{"keyframe", keyframeFrame},
+		{"empty keyframe", &Frame{Keyframe: &Keyframe{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decode(tt.frame.Encode())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !sameFrame(got, tt.frame) {
+				t.Errorf("round trip = %+v, want %+v", got, tt.frame)
+			}
+		})
+	}
+}
+
+func TestDecodeTruncated(t *testing.T) {
+	data := keyframeFrame.Encode()
+	for n := range len(data) {
+		if _, err := Decode(data[:n]); !errors.Is(err, errShortFrame) {
+			t.Errorf("decoding %d of %d bytes: err = %v, want a truncated frame", n, len(data), err)
+		}
+	}
+}
+
+func TestDecodeCorrupt(t *testing.T) {
+	header := []byte{frameVersion, 0, 1, 0, 0, 0, 0, 0}
+	keyHeader := slices.Concat([]byte{frameVersion, 1}, header[2:])
+	actor := make([]byte, 10)
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"unknown version", []byte{frameVersion + 1, 0}},
+		{"overlong varint", []byte{frameVersion, 0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}},
+		{"ghost count beyond the data", slices.Concat(header, actor, []byte{0xff, 0xff, 0xff, 0xff, 0x0f})},
+		{"layout row beyond the data", slices.Concat(keyHeader, actor, []byte{0, 0, 16, 1, 50, '#'})},
+		{"keyframe flag without keyframe", slices.Concat(keyHeader, actor, []byte{0, 0})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if f, err := Decode(tt.data); err == nil {
+				t.Errorf("decoded %+v, want an error", f)
+			}
+		})
+	}
+}
+
+func TestKeyframeAfterDrop(t *testing.T) {
+	s := NewServer()
+	c := &client{out: make(chan []byte, 2)}
+	s.clients[c] = struct{}{}
+
+	s.Publish(&Frame{Frame: 1})
+	s.Publish(&Frame{Frame: 2})
+	if s.NeedsKeyframe() {
+		t.Fatal("asked for a keyframe before any frame was dropped")
+	}
+	s.Publish(&Frame{Frame: 3})
+	if !s.NeedsKeyframe() {
+		t.Fatal("a dropped frame did not ask for a keyframe")
+	}
+
+	<-c.out
+	<-c.out
+	s.Publish(keyframeFrame)
+	if s.NeedsKeyframe() {
+		t.Error("still asking for a keyframe after sending one")
+	}
+	f, err := Decode(<-c.out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Keyframe == nil {
+		t.Error("the spectator that fell behind got no keyframe")
+	}
+}
@@ -0,0 +1,107 @@
+package broadcast
+
+import (
+	"io"
+	"net/http"
+	"sync"
+
+	"golang.org/x/net/websocket"
+)
+
+// Path is where spectators connect on a broadcasting game.
+const Path = "/spectate"
+
+// clientBuffer is how many frames may queue for a slow spectator before frames are dropped.
+const clientBuffer = 120
+
+// Server fans encoded frames out to any number of read-only spectators.
+type Server struct {
+	mu       sync.Mutex
+	clients  map[*client]struct{}
+	keyframe bool
+}
+
+type client struct {
+	out chan []byte
+}
+
+// NewServer returns a server with no spectators attached.
+func NewServer() *Server {
+	return &Server{clients: map[*client]struct{}{}}
+}
+
+// ListenAndServe accepts spectators on addr until the listener fails.
+func (s *Server) ListenAndServe(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle(Path, websocket.Handler(s.serve))
+	return http.ListenAndServe(addr, mux)
+}
+
+// NeedsKeyframe reports whether the next published frame must carry a keyframe,
+// either because a spectator just joined or because one missed frames.
+func (s *Server) NeedsKeyframe() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.keyframe
+}
+
+// Spectators returns the number of connected spectators.
+func (s *Server) Spectators() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.clients)
+}
+
+// Publish queues the frame for every spectator without blocking the game loop.
+func (s *Server) Publish(f *Frame) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.clients) == 0 {
+		return
+	}
+	if f.Keyframe != nil {
+		s.keyframe = false
+	}
+	data := f.Encode()
+	for c := range s.clients {
+		select {
+		case c.out <- data:
+		default:
+			// The spectator fell behind; a fresh keyframe brings it back in sync.
+			s.keyframe = true
+		}
+	}
+}
+
+func (s *Server) serve(ws *websocket.Conn) {
+	ws.PayloadType = websocket.BinaryFrame
+	c := &client{out: make(chan []byte, clientBuffer)}
+	s.mu.Lock()
+	s.clients[c] = struct{}{}
+	s.keyframe = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, c)
+		s.mu.Unlock()
+		ws.Close()
+	}()
+
+	// Spectators never send anything; reading only tells us when they leave.
+	done := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, ws)
+		close(done)
+	}()
+
+	for {
+		select {
+		case data := <-c.out:
+			if err := websocket.Message.Send(ws, data); err != nil {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
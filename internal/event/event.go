@@ -0,0 +1,102 @@
+// Package event carries gameplay events out of the simulation. Sound,
+// particles, stats and the like subscribe to a Bus instead of hooking into
+// the rules.
+package event
+
+import "github.com/sky0621/koro/internal/level"
+
+// Event is one of the types below.
+type Event interface {
+	event()
+}
+
+// PelletEaten is sent for every pellet Koro eats, small, power or special.
+type PelletEaten struct {
+	Pos    level.GridPos
+	Pellet level.PelletType
+	Points int
+}
+
+// PowerStarted is sent when a power pellet frightens the ghosts.
+type PowerStarted struct {
+	Frames int
+}
+
+// PowerEnded is sent when the ghosts stop being frightened.
+type PowerEnded struct{}
+
+// EffectStarted is sent when a special pellet or hazard starts a timed effect.
+type EffectStarted struct {
+	Name   string
+	Frames int
+}
+
+// GhostEaten is sent when Koro catches a frightened ghost.
+type GhostEaten struct {
+	Ghost  int
+	Pos    level.GridPos
+	Points int
+}
+
+// ShieldBroken is sent when the shield absorbs a catch.
+type ShieldBroken struct{}
+
+// PlayerDied is sent when a ghost catches Koro.
+type PlayerDied struct {
+	Pos       level.GridPos
+	LivesLeft int
+}
+
+// GameOver is sent when the last life is lost.
+type GameOver struct {
+	Score int
+}
+
+// LevelCleared is sent when the last pellet of a level is eaten.
+type LevelCleared struct {
+	Level int
+	Score int
+}
+
+func (PelletEaten) event()   {}
+func (PowerStarted) event()  {}
+func (PowerEnded) event()    {}
+func (EffectStarted) event() {}
+func (GhostEaten) event()    {}
+func (ShieldBroken) event()  {}
+func (PlayerDied) event()    {}
+func (GameOver) event()      {}
+func (LevelCleared) event()  {}
+
+// Bus delivers events to subscribers in the order they subscribed, as they
+// are published. Subscribers only observe: they run inside the simulation
+// step, so they must not change game state.
+type Bus struct {
+	handlers []func(Event)
+}
+
+// NewBus returns a bus without subscribers.
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe registers fn for every event.
+func (b *Bus) Subscribe(fn func(Event)) {
+	b.handlers = append(b.handlers, fn)
+}
+
+// On registers fn for the events of type T only.
+func On[T Event](b *Bus, fn func(T)) {
+	b.Subscribe(func(e Event) {
+		if t, ok := e.(T); ok {
+			fn(t)
+		}
+	})
+}
+
+// Publish delivers e to every subscriber.
+func (b *Bus) Publish(e Event) {
+	for _, fn := range b.handlers {
+		fn(e)
+	}
+}
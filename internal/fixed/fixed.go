@@ -0,0 +1,83 @@
+// Package fixed provides the fixed-point numbers the simulation runs on.
+//
+// Floating-point results are not guaranteed to match between CPUs (arm64
+// fuses multiply-adds that amd64 rounds twice, for one), which is enough to
+// split a replay or a netplay session apart. Integer arithmetic cannot
+// diverge, so every position, speed and distance in the simulation is a Num
+// and floats appear only when drawing.
+package fixed
+
+import (
+	"math"
+	"math/bits"
+)
+
+// Num is a signed fixed-point number with Shift fractional bits.
+type Num int64
+
+const (
+	Shift     = 16
+	One   Num = 1 << Shift
+	Half  Num = One / 2
+)
+
+// Int converts a whole number.
+func Int(i int) Num {
+	return Num(i) << Shift
+}
+
+// FromFloat converts a float, rounding to the nearest step. It is meant for
+// configuration such as command-line flags, never for simulation results.
+func FromFloat(f float64) Num {
+	return Num(math.Round(f * float64(One)))
+}
+
+// Float converts n for drawing.
+func (n Num) Float() float64 {
+	return float64(n) / float64(One)
+}
+
+// Mul returns n*m.
+func (n Num) Mul(m Num) Num {
+	return n * m >> Shift
+}
+
+// Div returns n/m.
+func (n Num) Div(m Num) Num {
+	return n << Shift / m
+}
+
+// Floor returns the largest whole number not above n.
+func (n Num) Floor() int {
+	return int(n >> Shift)
+}
+
+// Abs returns the absolute value of n.
+func (n Num) Abs() Num {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// Hypot returns sqrt(a*a + b*b).
+func Hypot(a, b Num) Num {
+	sq := uint64(a.Abs())*uint64(a.Abs()) + uint64(b.Abs())*uint64(b.Abs())
+	return Num(isqrt(sq))
+}
+
+// isqrt returns the largest r with r*r <= v.
+func isqrt(v uint64) uint64 {
+	if v == 0 {
+		return 0
+	}
+	// Start above the root and walk down with Newton's method.
+	r := uint64(1) << ((bits.Len64(v) + 1) / 2)
+	for {
+		next := (r + v/r) / 2
+		if next >= r {
+			return r
+		}
+		r = next
+	}
+}
@@ -0,0 +1,80 @@
+package fixed
+
+import "testing"
+
+func TestMul(t *testing.T) {
+	tests := []struct {
+		n, m, want Num
+	}{
+		{Int(3), Int(4), Int(12)},
+		{Half, Half, One / 4},
+		{Int(-2), Int(3), Int(-6)},
+		{Int(-2), Int(-3), Int(6)},
+		{FromFloat(1.5), Int(2), Int(3)},
+		{Int(7), 0, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.n.Mul(tt.m); got != tt.want {
+			t.Errorf("%v.Mul(%v) = %v, want %v", tt.n.Float(), tt.m.Float(), got.Float(), tt.want.Float())
+		}
+	}
+}
+
+func TestDiv(t *testing.T) {
+	tests := []struct {
+		n, m, want Num
+	}{
+		{Int(12), Int(4), Int(3)},
+		{One, Int(4), One / 4},
+		{Int(3), Int(2), FromFloat(1.5)},
+		{Int(-6), Int(3), Int(-2)},
+		{Int(-6), Int(-3), Int(2)},
+		{0, Int(5), 0},
+	}
+	for _, tt := range tests {
+		if got := tt.n.Div(tt.m); got != tt.want {
+			t.Errorf("%v.Div(%v) = %v, want %v", tt.n.Float(), tt.m.Float(), got.Float(), tt.want.Float())
+		}
+	}
+}
+
+func TestHypot(t *testing.T) {
+	tests := []struct {
+		a, b, want Num
+	}{
+		{0, 0, 0},
+		{Int(3), Int(4), Int(5)},
+		{Int(-3), Int(4), Int(5)},
+		{Int(5), Int(-12), Int(13)},
+		{Int(7), 0, Int(7)},
+		{0, Int(-7), Int(7)},
+		{Half, 0, Half},
+		// sqrt(2) rounded down to the nearest step.
+		{One, One, 92681},
+		// Far beyond any maze, to keep clear of overflow.
+		{Int(30000), Int(40000), Int(50000)},
+	}
+	for _, tt := range tests {
+		if got := Hypot(tt.a, tt.b); got != tt.want {
+			t.Errorf("Hypot(%v, %v) = %v, want %v", tt.a.Float(), tt.b.Float(), got, tt.want)
+		}
+	}
+}
+
+func TestFloor(t *testing.T) {
+	tests := []struct {
+		n    Num
+		want int
+	}{
+		{Int(2), 2},
+		{Int(2) + Half, 2},
+		{Int(2) - 1, 1},
+		{-Half, -1},
+		{Int(-2), -2},
+	}
+	for _, tt := range tests {
+		if got := tt.n.Floor(); got != tt.want {
+			t.Errorf("(%v).Floor() = %d, want %d", tt.n.Float(), got, tt.want)
+		}
+	}
+}
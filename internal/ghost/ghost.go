@@ -6,81 +6,213 @@ import (
 	"math/rand"
 	"time"
 
+	"github.com/sky0621/koro/internal/fixed"
 	"github.com/sky0621/koro/internal/koro"
 	"github.com/sky0621/koro/internal/level"
+	"github.com/sky0621/koro/internal/rng"
 )
 
+// Chances are per mille so that decisions stay in integer arithmetic.
 const (
-	randomChangeChance       = 0.12
-	randomChangeFrighten     = 0.35
-	randomTurnChance         = 0.35
-	randomTurnChanceScared   = 0.7
-	randomScoreJitter        = 70.0
-	visitPenaltyWeight       = 22.0
-	targetChangeChance       = 0.08
-	targetChangeChanceScared = 0.4
+	randomChangeChance       = 120
+	randomChangeFrighten     = 350
+	randomTurnChance         = 350
+	randomTurnChanceScared   = 700
+	targetChangeChance       = 80
+	targetChangeChanceScared = 400
 	targetOverrideDuration   = 180
 )
 
+// Route scores are in tiles.
+const (
+	randomScoreJitter  = fixed.One * 35 / 8
+	visitPenaltyWeight = fixed.One * 11 / 8
+)
+
+const (
+	// baseSpeed is in tiles per frame.
+	baseSpeed       = fixed.One * 17 / 200
+	frightenedSpeed = baseSpeed * 3 / 4
+)
+
+// FrightenedColor is the body color of every ghost while it is vulnerable.
+var FrightenedColor color.Color = color.RGBA{0, 0, 255, 255}
+
+// StunnedColor is the body color of a ghost frozen by Koro's pulse.
+var StunnedColor color.Color = color.RGBA{140, 140, 140, 255}
+
 // Ghost encapsulates enemy behaviour with simple chase logic.
 type Ghost struct {
 	body                *koro.Koro
-	baseSpeed           float64
 	primaryColor        color.Color
 	frightenedTimer     int
+	stunTimer           int
 	rng                 *rand.Rand
-	spawnX              float64
-	spawnY              float64
+	rngSource           *rng.Source
+	spawn               level.GridPos
 	visited             map[level.GridPos]int
 	targetOverrideTimer int
-	overrideX           float64
-	overrideY           float64
+	override            level.GridPos
+	controlled          bool
+	requested           koro.Direction
+	heading             koro.Direction
+	lastTarget          level.GridPos
+	lastChoice          koro.Direction
 }
 
-// New creates a new ghost positioned at (x, y).
-func New(x, y, tileSize float64, clr color.Color) *Ghost {
-	body := koro.New(x, y, tileSize)
-	body.SetSpeed(1.35)
+// New creates a new ghost on tile pos, drawn tileSize pixels wide.
+func New(pos level.GridPos, tileSize float64, clr color.Color) *Ghost {
+	body := koro.New(pos, tileSize)
+	body.SetSpeed(baseSpeed)
+	body.Class = level.ActorGhost
 	g := &Ghost{
 		body:         body,
-		baseSpeed:    body.Speed,
 		primaryColor: clr,
-		spawnX:       x,
-		spawnY:       y,
-		rng:          rand.New(rand.NewSource(time.Now().UnixNano())),
+		spawn:        pos,
 		visited:      map[level.GridPos]int{},
 	}
-	g.RespawnAt(x, y)
+	g.Seed(time.Now().UnixNano())
+	g.RespawnAt(pos)
 	return g
 }
 
-// Update advances the ghost AI and movement.
-func (g *Ghost) Update(l *level.Level, targetX, targetY float64) {
+// Seed replaces the AI's random source so runs can be replayed deterministically.
+func (g *Ghost) Seed(seed int64) {
+	g.rng, g.rngSource = rng.New(seed)
+}
+
+// State is the mutable part of a ghost, captured for snapshots and replays.
+type State struct {
+	Body                koro.State
+	FrightenedTimer     int
+	StunTimer           int
+	RNG                 uint64
+	Spawn               level.GridPos
+	Visited             map[level.GridPos]int
+	TargetOverrideTimer int
+	Override            level.GridPos
+	Controlled          bool
+	Requested           koro.Direction
+	Heading             koro.Direction
+	Target              level.GridPos
+	Choice              koro.Direction
+}
+
+// Snapshot captures the ghost's AI and movement state.
+func (g *Ghost) Snapshot() State {
+	return State{
+		Body:                g.body.Snapshot(),
+		FrightenedTimer:     g.frightenedTimer,
+		StunTimer:           g.stunTimer,
+		RNG:                 g.rngSource.State(),
+		Spawn:               g.spawn,
+		Visited:             copyVisits(g.visited),
+		TargetOverrideTimer: g.targetOverrideTimer,
+		Override:            g.override,
+		Controlled:          g.controlled,
+		Requested:           g.requested,
+		Heading:             g.heading,
+		Target:              g.lastTarget,
+		Choice:              g.lastChoice,
+	}
+}
+
+// Restore rewinds the ghost to a previously captured state.
+func (g *Ghost) Restore(s State) {
+	g.body.Restore(s.Body)
+	g.frightenedTimer = s.FrightenedTimer
+	g.stunTimer = s.StunTimer
+	g.rngSource.SetState(s.RNG)
+	g.spawn = s.Spawn
+	g.visited = copyVisits(s.Visited)
+	g.targetOverrideTimer = s.TargetOverrideTimer
+	g.override = s.Override
+	g.controlled = s.Controlled
+	g.requested = s.Requested
+	g.heading = s.Heading
+	g.lastTarget = s.Target
+	g.lastChoice = s.Choice
+}
+
+func copyVisits(src map[level.GridPos]int) map[level.GridPos]int {
+	out := make(map[level.GridPos]int, len(src))
+	for k, v := range src {
+		out[k] = v
+	}
+	return out
+}
+
+// Freeze holds the ghost in place for one frame while its frightened and
+// stun timers keep running.
+func (g *Ghost) Freeze() {
 	if g.frightenedTimer > 0 {
 		g.frightenedTimer--
 	}
+	if g.stunTimer > 0 {
+		g.stunTimer--
+	}
+}
+
+// Update advances the ghost AI and movement, chasing the target tile.
+func (g *Ghost) Update(l *level.Level, target level.GridPos) {
+	stunned := g.IsStunned()
+	g.Freeze()
+	if stunned {
+		return
+	}
 
 	if g.IsFrightened() {
-		g.body.SetSpeed(g.baseSpeed * 0.75)
+		g.body.SetSpeed(frightenedSpeed)
 	} else {
-		g.body.SetSpeed(g.baseSpeed)
+		g.body.SetSpeed(baseSpeed)
 	}
 
-	tx, ty := g.determineTarget(l, targetX, targetY)
-	if dir := g.nextDirection(l, tx, ty); dir != koro.DirNone {
+	var dir koro.Direction
+	if g.controlled {
+		dir = g.controlledDirection(l)
+		g.lastTarget = g.body.Tile()
+	} else {
+		t := g.determineTarget(l, target)
+		dir = g.nextDirection(l, t)
+		g.lastTarget = t
+	}
+	g.lastChoice = dir
+	if dir != koro.DirNone {
 		g.body.SetIntentDirection(dir)
 	} else if current := g.body.Direction(); current != koro.DirNone {
 		g.body.SetIntentDirection(current)
 	}
 
 	g.body.Update(l)
+	if current := g.body.Direction(); current != koro.DirNone {
+		g.heading = current
+	}
 	g.recordVisit(l)
 }
 
+// SetPlayerControlled hands steering over to SetControlDirection instead of the AI.
+func (g *Ghost) SetPlayerControlled(enabled bool) {
+	g.controlled = enabled
+	g.requested = koro.DirNone
+}
+
+// IsPlayerControlled reports whether a human is steering the ghost.
+func (g *Ghost) IsPlayerControlled() bool {
+	return g.controlled
+}
+
+// SetControlDirection stores the direction requested by the controlling player.
+func (g *Ghost) SetControlDirection(dir koro.Direction) {
+	g.requested = dir
+}
+
 // Color returns the draw color according to current state.
 func (g *Ghost) Color() color.Color {
+	if g.IsStunned() {
+		return StunnedColor
+	}
 	if g.IsFrightened() {
-		return color.RGBA{0, 0, 255, 255}
+		return FrightenedColor
 	}
 	return g.primaryColor
 }
@@ -92,6 +224,19 @@ func (g *Ghost) SetFrightened(duration int) {
 	}
 }
 
+// Stun stops the ghost in place for the provided frame duration. A stunned
+// ghost cannot catch Koro.
+func (g *Ghost) Stun(duration int) {
+	if duration > g.stunTimer {
+		g.stunTimer = duration
+	}
+}
+
+// IsStunned reports whether the ghost is currently stunned.
+func (g *Ghost) IsStunned() bool {
+	return g.stunTimer > 0
+}
+
 // IsFrightened reports whether the ghost is currently vulnerable.
 func (g *Ghost) IsFrightened() bool {
 	return g.frightenedTimer > 0
@@ -99,22 +244,24 @@ func (g *Ghost) IsFrightened() bool {
 
 // Reset moves the ghost back to its spawn point.
 func (g *Ghost) Reset() {
-	g.RespawnAt(g.spawnX, g.spawnY)
+	g.RespawnAt(g.spawn)
 }
 
-// RespawnAt teleports the ghost to a new spawn position.
-func (g *Ghost) RespawnAt(x, y float64) {
-	g.spawnX = x
-	g.spawnY = y
-	g.body.SetPosition(x, y)
+// RespawnAt teleports the ghost to a new spawn tile.
+func (g *Ghost) RespawnAt(pos level.GridPos) {
+	g.spawn = pos
+	g.body.SetPosition(pos)
 	g.body.SetIntentDirection(koro.DirNone)
 	g.frightenedTimer = 0
+	g.stunTimer = 0
 	g.visited = map[level.GridPos]int{}
+	g.requested = koro.DirNone
+	g.heading = koro.DirNone
 }
 
-// Position returns the current location.
-func (g *Ghost) Position() (float64, float64) {
-	return g.body.X, g.body.Y
+// Pixels returns the top-left corner of the ghost in pixels, for drawing.
+func (g *Ghost) Pixels() (float64, float64) {
+	return g.body.Pixels()
 }
 
 // Size returns the body size in pixels.
@@ -122,18 +269,24 @@ func (g *Ghost) Size() float64 {
 	return g.body.Size
 }
 
+// Decision returns the target the ghost steered towards on its last update and
+// the direction it picked there (DirNone when it kept its heading).
+func (g *Ghost) Decision() (target level.GridPos, dir koro.Direction) {
+	return g.lastTarget, g.lastChoice
+}
+
 // Body returns the internal mover component.
 func (g *Ghost) Body() *koro.Koro {
 	return g.body
 }
 
-func (g *Ghost) nextDirection(l *level.Level, targetX, targetY float64) koro.Direction {
+func (g *Ghost) nextDirection(l *level.Level, target level.GridPos) koro.Direction {
 	current := g.body.Direction()
 	changeChance := randomChangeChance
 	if g.IsFrightened() {
 		changeChance = randomChangeFrighten
 	}
-	randomRecalc := changeChance > 0 && g.rng.Float64() < changeChance
+	randomRecalc := changeChance > 0 && g.rng.Intn(1000) < changeChance
 	needDecision := current == koro.DirNone || !g.body.CanMove(l, current) || g.atIntersection(l) || randomRecalc
 	if !needDecision {
 		return koro.DirNone
@@ -153,23 +306,30 @@ func (g *Ghost) nextDirection(l *level.Level, targetX, targetY float64) koro.Dir
 	if g.IsFrightened() {
 		randomTurn = randomTurnChanceScared
 	}
-	if g.rng.Float64() < randomTurn {
+	if g.rng.Intn(1000) < randomTurn {
 		return options[g.rng.Intn(len(options))]
 	}
 
-	cx, cy := g.body.Center()
-	tileSize := float64(l.TileSize)
+	here := g.body.Tile()
+	field := l.DistanceField(target)
 	bestDir := options[0]
-	bestScore := math.MaxFloat64
+	bestScore := fixed.Num(math.MaxInt64)
 
 	for _, dir := range options {
-		dx, dy := dir.Delta()
-		nextX := cx + float64(dx)*tileSize
-		nextY := cy + float64(dy)*tileSize
-		dist := math.Hypot(targetX-nextX, targetY-nextY)
 		grid := g.gridAhead(l, dir)
-		visitScore := float64(g.visited[grid]) * visitPenaltyWeight
-		score := dist + visitScore + g.rng.Float64()*randomScoreJitter
+		// Walking distance keeps ghosts from hugging walls that sit between
+		// them and the target; straight-line distance is the fallback when the
+		// target tile cannot be reached at all.
+		var dist fixed.Num
+		if steps := field.At(grid); steps != level.Unreachable {
+			dist = fixed.Int(steps)
+		} else {
+			dx, dy := dir.Delta()
+			dist = fixed.Hypot(fixed.Int(target.Col-here.Col-dx), fixed.Int(target.Row-here.Row-dy))
+		}
+		visitScore := fixed.Num(g.visited[grid]) * visitPenaltyWeight
+		jitter := fixed.Num(g.rng.Int63n(int64(randomScoreJitter)))
+		score := dist + visitScore + jitter
 		if score < bestScore {
 			bestScore = score
 			bestDir = dir
@@ -179,25 +339,63 @@ func (g *Ghost) nextDirection(l *level.Level, targetX, targetY float64) koro.Dir
 	return bestDir
 }
 
+// controlledDirection applies the player's request under the same rules the AI
+// follows: turns happen at tile centres and reversing is only allowed at dead ends.
+func (g *Ghost) controlledDirection(l *level.Level) koro.Direction {
+	req := g.requested
+	if req == koro.DirNone || req == g.body.Direction() {
+		return koro.DirNone
+	}
+	if g.tileAhead(l, req) == level.TileWall {
+		return koro.DirNone
+	}
+	if req == oppositeDirection(g.heading) {
+		if !g.atDeadEnd(l) {
+			return koro.DirNone
+		}
+		return req
+	}
+	if g.body.Direction() != koro.DirNone && !g.body.AtCentre() {
+		return koro.DirNone
+	}
+	return req
+}
+
+func (g *Ghost) atDeadEnd(l *level.Level) bool {
+	back := oppositeDirection(g.heading)
+	for _, dir := range []koro.Direction{koro.DirUp, koro.DirDown, koro.DirLeft, koro.DirRight} {
+		if dir != back && g.tileAhead(l, dir) != level.TileWall {
+			return false
+		}
+	}
+	return true
+}
+
+func (g *Ghost) tileAhead(l *level.Level, dir koro.Direction) level.TileType {
+	grid := g.gridAhead(l, dir)
+	return l.TileAt(grid.Col, grid.Row)
+}
+
 func (g *Ghost) shuffleDirections(dirs []koro.Direction) {
 	g.rng.Shuffle(len(dirs), func(i, j int) {
 		dirs[i], dirs[j] = dirs[j], dirs[i]
 	})
 }
 
+// atIntersection reports whether the ghost stands on the centre of a
+// junction or dead end in the level's navigation graph. The body stops on
+// every centre it reaches, so none is ever skipped.
 func (g *Ghost) atIntersection(l *level.Level) bool {
-	cx, cy := g.body.Center()
-	grid := l.GridForPixel(cx, cy)
-	tile := float64(l.TileSize)
-	centerX := float64(grid.Col)*tile + tile/2
-	centerY := float64(grid.Row)*tile + tile/2
-	tolerance := tile * 0.3
-	return math.Abs(centerX-cx) < tolerance && math.Abs(centerY-cy) < tolerance
+	return g.body.AtCentre() && l.Graph().IsDecisionPoint(g.body.Tile())
 }
 
 func (g *Ghost) availableDirections(l *level.Level) []koro.Direction {
 	current := g.body.Direction()
 	opposite := oppositeDirection(current)
+	// Between centres the body can only go on or turn back.
+	canGo := func(dir koro.Direction) bool {
+		return g.body.CanMove(l, dir)
+	}
 
 	dirs := []koro.Direction{koro.DirUp, koro.DirDown, koro.DirLeft, koro.DirRight}
 	valid := dirs[:0]
@@ -205,61 +403,53 @@ func (g *Ghost) availableDirections(l *level.Level) []koro.Direction {
 		if dir == opposite {
 			continue
 		}
-		if g.body.CanMove(l, dir) {
+		if canGo(dir) {
 			valid = append(valid, dir)
 		}
 	}
-	if len(valid) == 0 && opposite != koro.DirNone && g.body.CanMove(l, opposite) {
+	if len(valid) == 0 && opposite != koro.DirNone && canGo(opposite) {
 		return []koro.Direction{opposite}
 	}
 	return valid
 }
 
 func (g *Ghost) recordVisit(l *level.Level) {
-	cx, cy := g.body.Center()
-	grid := l.GridForPixel(cx, cy)
-	g.visited[grid]++
+	g.visited[g.body.Tile()]++
 }
 
 func (g *Ghost) gridAhead(l *level.Level, dir koro.Direction) level.GridPos {
-	cx, cy := g.body.Center()
+	grid := g.body.Tile()
 	dx, dy := dir.Delta()
-	nextX := cx + float64(dx)*float64(l.TileSize)
-	nextY := cy + float64(dy)*float64(l.TileSize)
-	return l.GridForPixel(nextX, nextY)
+	return level.GridPos{Col: grid.Col + dx, Row: grid.Row + dy}
 }
 
-func (g *Ghost) determineTarget(l *level.Level, defaultX, defaultY float64) (float64, float64) {
+func (g *Ghost) determineTarget(l *level.Level, fallback level.GridPos) level.GridPos {
 	if g.targetOverrideTimer > 0 {
 		g.targetOverrideTimer--
-		return g.overrideX, g.overrideY
+		return g.override
 	}
 
 	chance := targetChangeChance
 	if g.IsFrightened() {
 		chance = targetChangeChanceScared
 	}
-	if g.rng.Float64() < chance {
-		if tx, ty, ok := g.randomTarget(l); ok {
-			g.overrideX = tx
-			g.overrideY = ty
+	if g.rng.Intn(1000) < chance {
+		if t, ok := g.randomTarget(l); ok {
+			g.override = t
 			g.targetOverrideTimer = targetOverrideDuration
-			return tx, ty
+			return t
 		}
 	}
 
-	return defaultX, defaultY
+	return fallback
 }
 
-func (g *Ghost) randomTarget(l *level.Level) (float64, float64, bool) {
+func (g *Ghost) randomTarget(l *level.Level) (level.GridPos, bool) {
 	walkable := l.WalkableTiles()
 	if len(walkable) == 0 {
-		return 0, 0, false
+		return level.GridPos{}, false
 	}
-	pos := walkable[g.rng.Intn(len(walkable))]
-	tx := float64(pos.Col*l.TileSize) + float64(l.TileSize)/2
-	ty := float64(pos.Row*l.TileSize) + float64(l.TileSize)/2
-	return tx, ty, true
+	return walkable[g.rng.Intn(len(walkable))], true
 }
 
 func oppositeDirection(dir koro.Direction) koro.Direction {
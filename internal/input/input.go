@@ -2,18 +2,68 @@ package input
 
 import (
 	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
 
 	"github.com/sky0621/koro/internal/koro"
 )
 
+// Bindings selects the keys and gamepad that feed a Manager.
+type Bindings struct {
+	Left, Right, Up, Down ebiten.Key
+	// Abilities maps each of Koro's abilities to its key; gamepads use the
+	// face buttons in abilityButtons. Layouts that don't steer Koro leave it
+	// empty.
+	Abilities map[koro.Ability]ebiten.Key
+	// Gamepad is the index into the connected gamepads, or -1 to accept any of them.
+	Gamepad int
+}
+
+var (
+	// ArrowKeys is the default single-player layout.
+	ArrowKeys = Bindings{
+		Left:    ebiten.KeyArrowLeft,
+		Right:   ebiten.KeyArrowRight,
+		Up:      ebiten.KeyArrowUp,
+		Down:    ebiten.KeyArrowDown,
+		Gamepad: -1,
+		Abilities: map[koro.Ability]ebiten.Key{
+			koro.AbilityDash:  ebiten.KeySpace,
+			koro.AbilityPulse: ebiten.KeyX,
+		},
+	}
+	// WASDKeys is the second player's layout in shared-screen modes. The
+	// second player steers the rival ghost, which has no abilities.
+	WASDKeys = Bindings{
+		Left:    ebiten.KeyA,
+		Right:   ebiten.KeyD,
+		Up:      ebiten.KeyW,
+		Down:    ebiten.KeyS,
+		Gamepad: 1,
+	}
+)
+
+// abilityButtons are the gamepad buttons for the abilities: the bottom and
+// right face buttons.
+var abilityButtons = [koro.AbilityCount]ebiten.StandardGamepadButton{
+	koro.AbilityDash:  ebiten.StandardGamepadButtonRightBottom,
+	koro.AbilityPulse: ebiten.StandardGamepadButtonRightRight,
+}
+
 // Manager normalises keyboard/gamepad input to a single direction.
 type Manager struct {
-	current koro.Direction
+	bindings Bindings
+	current  koro.Direction
+	actions  [koro.AbilityCount]bool
 }
 
 // NewManager creates an input manager with default thresholds.
 func NewManager() *Manager {
-	return &Manager{}
+	return NewManagerWithBindings(ArrowKeys)
+}
+
+// NewManagerWithBindings creates an input manager reading only the provided devices.
+func NewManagerWithBindings(b Bindings) *Manager {
+	return &Manager{bindings: b}
 }
 
 // Update samples the current input devices.
@@ -23,6 +73,16 @@ func (m *Manager) Update() {
 		dir = m.gamepadDirection()
 	}
 	m.current = dir
+	for ability, key := range m.bindings.Abilities {
+		m.actions[ability] = inpututil.IsKeyJustPressed(key) ||
+			m.gamepadJustPressed(abilityButtons[ability])
+	}
+}
+
+// Action reports whether the button for ability was pressed this frame.
+// Holding it down fires the ability once, not again as the cooldown ends.
+func (m *Manager) Action(ability koro.Ability) bool {
+	return m.actions[ability]
 }
 
 // Direction returns the latest requested direction.
@@ -32,21 +92,36 @@ func (m *Manager) Direction() koro.Direction {
 
 func (m *Manager) keyboardDirection() koro.Direction {
 	switch {
-	case ebiten.IsKeyPressed(ebiten.KeyArrowLeft):
+	case ebiten.IsKeyPressed(m.bindings.Left):
 		return koro.DirLeft
-	case ebiten.IsKeyPressed(ebiten.KeyArrowRight):
+	case ebiten.IsKeyPressed(m.bindings.Right):
 		return koro.DirRight
-	case ebiten.IsKeyPressed(ebiten.KeyArrowUp):
+	case ebiten.IsKeyPressed(m.bindings.Up):
 		return koro.DirUp
-	case ebiten.IsKeyPressed(ebiten.KeyArrowDown):
+	case ebiten.IsKeyPressed(m.bindings.Down):
 		return koro.DirDown
 	default:
 		return koro.DirNone
 	}
 }
 
+func (m *Manager) gamepadJustPressed(button ebiten.StandardGamepadButton) bool {
+	for i, id := range ebiten.GamepadIDs() {
+		if m.bindings.Gamepad >= 0 && i != m.bindings.Gamepad {
+			continue
+		}
+		if ebiten.IsStandardGamepadLayoutAvailable(id) && inpututil.IsStandardGamepadButtonJustPressed(id, button) {
+			return true
+		}
+	}
+	return false
+}
+
 func (m *Manager) gamepadDirection() koro.Direction {
-	for _, id := range ebiten.GamepadIDs() {
+	for i, id := range ebiten.GamepadIDs() {
+		if m.bindings.Gamepad >= 0 && i != m.bindings.Gamepad {
+			continue
+		}
 		if !ebiten.IsStandardGamepadLayoutAvailable(id) {
 			continue
 		}
@@ -0,0 +1,88 @@
+package koro
+
+// Ability is an active power fired from an action button.
+type Ability int
+
+const (
+	// AbilityDash doubles Koro's speed for a moment.
+	AbilityDash Ability = iota
+	// AbilityPulse stuns the ghosts next to Koro; the game applies the stun.
+	AbilityPulse
+	AbilityCount
+)
+
+// Abilities selects which abilities can be used.
+type Abilities struct {
+	Dash  bool
+	Pulse bool
+}
+
+// Timings in frames at 60 FPS.
+const (
+	DashFrames    = 30
+	DashCooldown  = 180
+	PulseCooldown = 300
+	// DashFactor multiplies the step speed while dashing.
+	DashFactor = 2
+)
+
+var abilityCooldowns = [AbilityCount]int{
+	AbilityDash:  DashCooldown,
+	AbilityPulse: PulseCooldown,
+}
+
+// Has reports whether ability is enabled.
+func (a Abilities) Has(ability Ability) bool {
+	switch ability {
+	case AbilityDash:
+		return a.Dash
+	case AbilityPulse:
+		return a.Pulse
+	default:
+		return false
+	}
+}
+
+// SetAbilities chooses which abilities Use accepts.
+func (k *Koro) SetAbilities(a Abilities) {
+	k.abilities = a
+}
+
+// Abilities returns the enabled abilities.
+func (k *Koro) Abilities() Abilities {
+	return k.abilities
+}
+
+// Use fires ability if it is enabled and off cooldown, and reports whether it did.
+func (k *Koro) Use(ability Ability) bool {
+	if !k.abilities.Has(ability) || k.cooldowns[ability] > 0 {
+		return false
+	}
+	k.cooldowns[ability] = abilityCooldowns[ability]
+	if ability == AbilityDash {
+		k.dashTimer = DashFrames
+	}
+	return true
+}
+
+// Cooldown returns the frames left before ability can be used again and its full cooldown.
+func (k *Koro) Cooldown(ability Ability) (left, total int) {
+	return k.cooldowns[ability], abilityCooldowns[ability]
+}
+
+// Dashing reports whether a dash is in progress.
+func (k *Koro) Dashing() bool {
+	return k.dashTimer > 0
+}
+
+// tickAbilities counts the ability timers down by one frame.
+func (k *Koro) tickAbilities() {
+	if k.dashTimer > 0 {
+		k.dashTimer--
+	}
+	for i := range k.cooldowns {
+		if k.cooldowns[i] > 0 {
+			k.cooldowns[i]--
+		}
+	}
+}
@@ -1,8 +1,7 @@
 package koro
 
 import (
-	"math"
-
+	"github.com/sky0621/koro/internal/fixed"
 	"github.com/sky0621/koro/internal/level"
 )
 
@@ -33,25 +32,74 @@ func (d Direction) Delta() (int, int) {
 }
 
 // Koro represents the controllable hero.
+//
+// Movement is tracked on the grid: Koro is always on its way from one tile
+// centre to the next, with progress measured in fractions of a tile. It stops
+// exactly on every centre it reaches, carrying any distance left over into
+// the next frame, so turns never need snapping and speeds need not divide the
+// tile size. All of it is fixed-point so every platform agrees on every frame.
 type Koro struct {
-	X, Y   float64
-	Size   float64
-	Speed  float64
-	dir    Direction
-	intent Direction
-}
-
-// New returns a configured Koro instance positioned at x,y with the provided size.
-func New(x, y, size float64) *Koro {
-	return &Koro{
-		X:    x,
-		Y:    y,
+	// Size is the drawn size in pixels, which is also the tile size.
+	Size float64
+	// Speed is in tiles per frame.
+	Speed fixed.Num
+	// Class picks the row of per-tile speed modifiers that applies.
+	Class level.ActorClass
+	// CornerWindow is how far, in tiles, before or after a tile centre a
+	// perpendicular turn may start early, cutting the corner diagonally.
+	// Zero turns only on the centre, as ghosts do.
+	CornerWindow fixed.Num
+	dir          Direction
+	intent       Direction
+
+	// tile is the centre Koro last stood on and progress how far it is
+	// towards the next one along dir, in [0, 1).
+	tile     level.GridPos
+	progress fixed.Num
+	// carry is distance that did not fit before the last centre.
+	carry fixed.Num
+	// offX and offY are what is left of a cut corner, in tiles off the
+	// centre line; Koro closes the gap as it moves.
+	offX, offY fixed.Num
+
+	// warpedTo is the warp exit Koro last arrived on; warps stay inactive
+	// until Koro leaves that tile so it does not bounce straight back.
+	warpedTo level.GridPos
+	warped   bool
+
+	abilities Abilities
+	dashTimer int
+	cooldowns [AbilityCount]int
+}
+
+// State is the mutable part of a Koro, captured for snapshots and replays.
+type State struct {
+	Tile       level.GridPos
+	Progress   fixed.Num
+	Carry      fixed.Num
+	OffX, OffY fixed.Num
+	Speed      fixed.Num
+	Dir        Direction
+	Intent     Direction
+
+	WarpedTo level.GridPos
+	Warped   bool
+
+	DashTimer int
+	Cooldowns [AbilityCount]int
+}
+
+// New returns a configured Koro instance on tile pos, drawn size pixels wide.
+func New(pos level.GridPos, size float64) *Koro {
+	k := &Koro{
 		Size: size,
-		// Speed is tuned for smooth per-frame pixel movement.
-		Speed:  1.5,
+		// A tenth of a tile per frame crosses a tile every 10 frames.
+		Speed:  fixed.One / 10,
 		dir:    DirNone,
 		intent: DirNone,
 	}
+	k.SetPosition(pos)
+	return k
 }
 
 // SetIntentDirection stores the desired direction from player input.
@@ -59,88 +107,263 @@ func (k *Koro) SetIntentDirection(dir Direction) {
 	k.intent = dir
 }
 
-// Update moves Koro according to queued directions and level collisions.
+// Update moves Koro according to queued directions and level walls.
 func (k *Koro) Update(l *level.Level) {
-	tileSize := float64(l.TileSize)
-	k.applyIntent(l, tileSize)
-
+	k.tickAbilities()
+	if k.AtCentre() {
+		k.dir = k.choose(l)
+	} else {
+		k.steer(l)
+	}
 	if k.dir == DirNone {
+		k.carry = 0
 		return
 	}
 
-	if k.canMove(l, k.dir) {
+	budget := k.StepSpeed(l, k.dir) + k.carry
+	k.carry = 0
+	k.closeCorner(budget)
+	if left := fixed.One - k.progress; budget >= left {
 		dx, dy := k.dir.Delta()
-		k.X += float64(dx) * k.Speed
-		k.Y += float64(dy) * k.Speed
-		k.handleWarp(l)
-		return
+		k.tile = level.GridPos{Col: k.tile.Col + dx, Row: k.tile.Row + dy}
+		k.progress = 0
+		k.offX, k.offY = 0, 0
+		k.carry = min(budget-left, fixed.One)
+		k.arrive(l)
+	} else {
+		k.progress += budget
 	}
-
-	k.snapToGrid(tileSize)
-	k.dir = DirNone
 }
 
-func (k *Koro) applyIntent(l *level.Level, tileSize float64) {
+// choose picks the heading on a tile centre: the request if it is open,
+// otherwise straight on, otherwise whatever the surface does.
+func (k *Koro) choose(l *level.Level) Direction {
 	if k.intent == DirNone {
-		k.dir = DirNone
-		return
+		return k.drift(l)
 	}
-
-	if k.intent != k.dir {
-		k.snapAxisForDirection(tileSize, k.intent)
+	if k.open(l, k.intent) {
+		return k.intent
+	}
+	// If the intended direction is blocked but Koro can still
+	// keep going straight, let it continue.
+	if k.dir != DirNone && k.open(l, k.dir) {
+		return k.dir
 	}
+	return k.drift(l)
+}
 
-	if k.canMove(l, k.intent) {
+// steer handles requests between centres: reversing is always allowed and a
+// perpendicular turn may cut the corner. Anything else waits for the centre.
+func (k *Koro) steer(l *level.Level) {
+	switch {
+	case k.offX != 0 || k.offY != 0:
+		// Still finishing a corner.
+	case k.intent == opposite(k.dir):
+		dx, dy := k.dir.Delta()
+		k.tile = level.GridPos{Col: k.tile.Col + dx, Row: k.tile.Row + dy}
+		k.progress = fixed.One - k.progress
 		k.dir = k.intent
-		return
+	case k.intent != DirNone && !sameAxis(k.dir, k.intent):
+		k.tryCorner(l)
 	}
+}
 
-	// If the intended direction is blocked but Koro can still
-	// keep going straight, let it continue.
-	if k.canMove(l, k.dir) {
-		return
+// drift is the heading Koro takes from a centre without a usable request:
+// ice keeps it sliding and conveyors carry it along their flow.
+func (k *Koro) drift(l *level.Level) Direction {
+	mod := l.ModifierAt(k.tile)
+	switch mod.Surface {
+	case level.SurfaceIce:
+		if k.dir != DirNone && k.open(l, k.dir) {
+			return k.dir
+		}
+	case level.SurfaceConveyor:
+		if dir := directionOf(mod.Flow); k.open(l, dir) {
+			return dir
+		}
 	}
+	return DirNone
+}
 
-	k.dir = DirNone
+// open reports whether the tile next to Koro's tile in dir can be entered.
+func (k *Koro) open(l *level.Level, dir Direction) bool {
+	dx, dy := dir.Delta()
+	return l.Walkable(level.GridPos{Col: k.tile.Col + dx, Row: k.tile.Row + dy})
 }
 
-func (k *Koro) canMove(l *level.Level, dir Direction) bool {
+// StepSpeed returns how far, in tiles, Koro moves this frame heading dir,
+// after the modifier of the tile it stands on and any dash.
+func (k *Koro) StepSpeed(l *level.Level, dir Direction) fixed.Num {
 	dx, dy := dir.Delta()
-	nextX := k.X + float64(dx)*k.Speed
-	nextY := k.Y + float64(dy)*k.Speed
-	return !l.Collides(nextX, nextY, k.Size)
+	speed := k.Speed.Mul(l.SpeedFactor(k.Tile(), k.Class, level.GridPos{Col: dx, Row: dy}))
+	if k.Dashing() {
+		speed *= DashFactor
+	}
+	return speed
 }
 
-// CanMove reports whether moving in the provided direction would collide.
+// Tile returns the tile whose centre Koro is closest to.
+func (k *Koro) Tile() level.GridPos {
+	if k.progress < fixed.Half {
+		return k.tile
+	}
+	dx, dy := k.dir.Delta()
+	return level.GridPos{Col: k.tile.Col + dx, Row: k.tile.Row + dy}
+}
+
+// AtCentre reports whether Koro stands exactly on a tile centre, the only
+// place perpendicular turns happen outside a cut corner.
+func (k *Koro) AtCentre() bool {
+	return k.progress == 0 && k.offX == 0 && k.offY == 0
+}
+
+// CanMove reports whether Koro can head in the provided direction now: on a
+// centre any open neighbour, between centres only along its current line.
 func (k *Koro) CanMove(l *level.Level, dir Direction) bool {
-	return k.canMove(l, dir)
+	if dir == DirNone {
+		return false
+	}
+	if k.AtCentre() {
+		return k.open(l, dir)
+	}
+	return dir == k.dir || dir == opposite(k.dir)
 }
 
-func (k *Koro) snapAxisForDirection(tileSize float64, dir Direction) {
-	switch dir {
-	case DirUp, DirDown:
-		k.X = math.Round(k.X/tileSize) * tileSize
-	case DirLeft, DirRight:
-		k.Y = math.Round(k.Y/tileSize) * tileSize
+// tryCorner starts a perpendicular turn up to CornerWindow tiles away from
+// the tile centre. Koro heads the new way at once and drifts onto the centre
+// line as it goes, so it gains ground over turning on the centre.
+func (k *Koro) tryCorner(l *level.Level) {
+	if k.CornerWindow <= 0 {
+		return
+	}
+	window := k.CornerWindow
+	dx, dy := k.dir.Delta()
+	switch {
+	case fixed.One-k.progress <= window:
+		// Just before the next centre: take the turn from there.
+		next := level.GridPos{Col: k.tile.Col + dx, Row: k.tile.Row + dy}
+		if _, ok := l.Warp(next); ok {
+			// The warp takes Koro away from next; turn after arriving.
+			return
+		}
+		ix, iy := k.intent.Delta()
+		if !l.Walkable(level.GridPos{Col: next.Col + ix, Row: next.Row + iy}) {
+			return
+		}
+		left := fixed.One - k.progress
+		k.offX, k.offY = -fixed.Num(dx)*left, -fixed.Num(dy)*left
+		k.tile = next
+	case k.progress <= window:
+		// Just past the centre: the request came late.
+		if !k.open(l, k.intent) {
+			return
+		}
+		k.offX, k.offY = fixed.Num(dx)*k.progress, fixed.Num(dy)*k.progress
+	default:
+		return
+	}
+	k.progress = 0
+	k.dir = k.intent
+}
+
+// closeCorner moves Koro up to step tiles back onto the centre line of its corridor.
+func (k *Koro) closeCorner(step fixed.Num) {
+	k.offX = towardZero(k.offX, step)
+	k.offY = towardZero(k.offY, step)
+}
+
+func towardZero(v, step fixed.Num) fixed.Num {
+	switch {
+	case v > step:
+		return v - step
+	case v < -step:
+		return v + step
+	default:
+		return 0
 	}
 }
 
-func (k *Koro) snapToGrid(tileSize float64) {
-	k.X = math.Round(k.X/tileSize) * tileSize
-	k.Y = math.Round(k.Y/tileSize) * tileSize
+func sameAxis(a, b Direction) bool {
+	ax, _ := a.Delta()
+	bx, _ := b.Delta()
+	return (ax == 0) == (bx == 0)
+}
+
+func opposite(dir Direction) Direction {
+	switch dir {
+	case DirLeft:
+		return DirRight
+	case DirRight:
+		return DirLeft
+	case DirUp:
+		return DirDown
+	case DirDown:
+		return DirUp
+	default:
+		return DirNone
+	}
 }
 
-func (k *Koro) handleWarp(l *level.Level) {
-	centerX := k.X + k.Size/2
-	centerY := k.Y + k.Size/2
-	grid := l.GridForPixel(centerX, centerY)
-	target, ok := l.WarpTarget(grid)
+// arrive runs on every centre Koro reaches and takes any warp there.
+func (k *Koro) arrive(l *level.Level) {
+	if k.warped {
+		if k.tile == k.warpedTo {
+			return
+		}
+		k.warped = false
+	}
+	w, ok := l.Warp(k.tile)
 	if !ok {
 		return
 	}
 
-	k.X = float64(target.Col * l.TileSize)
-	k.Y = float64(target.Row * l.TileSize)
+	k.tile = w.To
+	k.warpedTo, k.warped = w.To, true
+	if dir := directionOf(w.Exit); dir != DirNone {
+		k.dir = dir
+		k.intent = dir
+	}
+}
+
+// Pos returns the top-left corner of Koro in tiles.
+func (k *Koro) Pos() (x, y fixed.Num) {
+	dx, dy := k.dir.Delta()
+	x = fixed.Int(k.tile.Col) + fixed.Num(dx)*k.progress + k.offX
+	y = fixed.Int(k.tile.Row) + fixed.Num(dy)*k.progress + k.offY
+	return x, y
+}
+
+// Center returns the centre of Koro in tiles.
+func (k *Koro) Center() (x, y fixed.Num) {
+	x, y = k.Pos()
+	return x + fixed.Half, y + fixed.Half
+}
+
+// Pixels returns the top-left corner of Koro in pixels, for drawing.
+func (k *Koro) Pixels() (x, y float64) {
+	fx, fy := k.Pos()
+	return fx.Float() * k.Size, fy.Float() * k.Size
+}
+
+// PixelCenter returns the centre of Koro in pixels, for drawing.
+func (k *Koro) PixelCenter() (x, y float64) {
+	x, y = k.Pixels()
+	return x + k.Size/2, y + k.Size/2
+}
+
+func directionOf(step level.GridPos) Direction {
+	switch step {
+	case level.GridPos{Col: -1}:
+		return DirLeft
+	case level.GridPos{Col: 1}:
+		return DirRight
+	case level.GridPos{Row: -1}:
+		return DirUp
+	case level.GridPos{Row: 1}:
+		return DirDown
+	default:
+		return DirNone
+	}
 }
 
 // Direction returns the current heading.
@@ -148,20 +371,44 @@ func (k *Koro) Direction() Direction {
 	return k.dir
 }
 
-// SetPosition teleports Koro to the provided coordinates and clears direction state.
-func (k *Koro) SetPosition(x, y float64) {
-	k.X = x
-	k.Y = y
+// SetPosition moves Koro onto the centre of pos and clears direction state
+// and any dash in progress.
+func (k *Koro) SetPosition(pos level.GridPos) {
+	k.tile = pos
+	k.progress, k.carry = 0, 0
+	k.offX, k.offY = 0, 0
 	k.dir = DirNone
 	k.intent = DirNone
+	k.warped = false
+	k.dashTimer = 0
 }
 
-// Center returns the current center point coordinates.
-func (k *Koro) Center() (float64, float64) {
-	return k.X + k.Size/2, k.Y + k.Size/2
+// SetSpeed adjusts movement speed, in tiles per frame.
+func (k *Koro) SetSpeed(speed fixed.Num) {
+	k.Speed = speed
 }
 
-// SetSpeed adjusts movement speed.
-func (k *Koro) SetSpeed(speed float64) {
-	k.Speed = speed
+// Snapshot captures the current movement state.
+func (k *Koro) Snapshot() State {
+	return State{
+		Tile: k.tile, Progress: k.progress, Carry: k.carry, OffX: k.offX, OffY: k.offY,
+		Speed: k.Speed, Dir: k.dir, Intent: k.intent,
+		WarpedTo: k.warpedTo, Warped: k.warped,
+		DashTimer: k.dashTimer, Cooldowns: k.cooldowns,
+	}
+}
+
+// Restore rewinds Koro to a previously captured state.
+func (k *Koro) Restore(s State) {
+	k.tile = s.Tile
+	k.progress = s.Progress
+	k.carry = s.Carry
+	k.offX, k.offY = s.OffX, s.OffY
+	k.Speed = s.Speed
+	k.dir = s.Dir
+	k.intent = s.Intent
+	k.warpedTo = s.WarpedTo
+	k.warped = s.Warped
+	k.dashTimer = s.DashTimer
+	k.cooldowns = s.Cooldowns
 }
@@ -0,0 +1,44 @@
+package koro
+
+import (
+	"testing"
+
+	"github.com/sky0621/koro/internal/fixed"
+	"github.com/sky0621/koro/internal/level"
+)
+
+// TestCornerIntoWarp turns down just before a warp tile that has an opening
+// below it. Koro must take the warp rather than cut the corner past it.
+func TestCornerIntoWarp(t *testing.T) {
+	l, err := level.New([]string{
+		"#########",
+		"#P..1...#",
+		"####.####",
+		"#...2...#",
+		"#########",
+		"---",
+		"warp 1 -> 2",
+	}, level.DefaultTileSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, window := range []fixed.Num{0, fixed.One * 5 / 16} {
+		k := New(l.PlayerSpawn(), float64(l.TileSize))
+		k.CornerWindow = window
+		k.SetIntentDirection(DirRight)
+		visited := map[level.GridPos]bool{}
+		for range 120 {
+			if k.Tile().Col >= 3 {
+				k.SetIntentDirection(DirDown)
+			}
+			k.Update(l)
+			visited[k.Tile()] = true
+		}
+		if visited[level.GridPos{Col: 4, Row: 2}] {
+			t.Errorf("window %v: walked past the warp", window.Float())
+		}
+		if !visited[level.GridPos{Col: 4, Row: 3}] {
+			t.Errorf("window %v: never arrived at the warp exit", window.Float())
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package level
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// directives is the parsed metadata section of a layout.
+type directives struct {
+	links  []warpLink
+	exits  map[rune]GridPos
+	groups map[GridPos]string
+}
+
+type warpLink struct {
+	from, to rune
+	oneWay   bool
+}
+
+// parseDirectives reads the lines after MetadataSeparator, one directive per
+// line; blank lines and lines starting with // are skipped.
+//
+//	warp 1 -> 2          one-way from warp tile 1 to warp tile 2
+//	warp 1 <-> 2         two-way between warp tiles 1 and 2
+//	exit 2 left          actors leave warp tile 2 heading left
+//	group red 4,7 9,7    puts the switches and gates at col,row into group red
+func parseDirectives(meta []string, metaStart int) (directives, []Issue) {
+	d := directives{exits: map[rune]GridPos{}, groups: map[GridPos]string{}}
+	var issues []Issue
+	for i, line := range meta {
+		row := metaStart + i
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "//") {
+			continue
+		}
+		bad := func(msg string) {
+			issues = append(issues, Issue{Row: row, Col: 0, Severity: SeverityError, Code: "bad-directive", Message: msg})
+		}
+		switch {
+		case fields[0] == "warp" && len(fields) == 4 && (fields[2] == "->" || fields[2] == "<->"):
+			from, okFrom := warpLabel(fields[1])
+			to, okTo := warpLabel(fields[3])
+			if !okFrom || !okTo {
+				bad(fmt.Sprintf("warp labels must be single digits: %q", line))
+				continue
+			}
+			d.links = append(d.links, warpLink{from: from, to: to, oneWay: fields[2] == "->"})
+		case fields[0] == "exit" && len(fields) == 3:
+			label, ok := warpLabel(fields[1])
+			step, okDir := exitSteps[fields[2]]
+			if !ok || !okDir {
+				bad(fmt.Sprintf("expected \"exit <digit> left|right|up|down\": %q", line))
+				continue
+			}
+			d.exits[label] = step
+		case fields[0] == "group" && len(fields) >= 3:
+			for _, field := range fields[2:] {
+				pos, ok := parseColRow(field)
+				if !ok {
+					bad(fmt.Sprintf("group positions must be col,row: %q", field))
+					continue
+				}
+				d.groups[pos] = fields[1]
+			}
+		default:
+			bad(fmt.Sprintf("unknown directive %q", line))
+		}
+	}
+	return d, issues
+}
+
+func parseColRow(field string) (GridPos, bool) {
+	colText, rowText, ok := strings.Cut(field, ",")
+	if !ok {
+		return GridPos{}, false
+	}
+	col, errCol := strconv.Atoi(colText)
+	row, errRow := strconv.Atoi(rowText)
+	if errCol != nil || errRow != nil {
+		return GridPos{}, false
+	}
+	return GridPos{Col: col, Row: row}, true
+}
@@ -0,0 +1,267 @@
+package level
+
+import (
+	"maps"
+	"slices"
+)
+
+// FeatureKind identifies an interactive tile.
+type FeatureKind int
+
+const (
+	FeatureNone FeatureKind = iota
+	// FeatureDoor is a locked door ('A'-'E') that opens for the matching key.
+	FeatureDoor
+	// FeatureKey ('a'-'e') unlocks every door with the same letter when collected.
+	FeatureKey
+	// FeatureSwitch ('+') toggles every gate in its group when stepped on.
+	FeatureSwitch
+	// FeatureGate is a wall a switch opens and closes; '%' starts closed and '_' open.
+	FeatureGate
+)
+
+// Feature is the current state of an interactive tile.
+type Feature struct {
+	Kind FeatureKind
+	// Key is the lower-case letter tying doors to keys.
+	Key byte
+	// Group ties switches to gates; layouts without group directives use "".
+	Group string
+	// Open is set for an unlocked door, an open gate or a collected key.
+	Open bool
+}
+
+// solid reports whether the feature blocks movement.
+func (f Feature) solid() bool {
+	return (f.Kind == FeatureDoor || f.Kind == FeatureGate) && !f.Open
+}
+
+// TileChange describes a tile whose type changed during play.
+type TileChange struct {
+	Pos  GridPos
+	Tile TileType
+}
+
+// FeatureAt returns the interactive tile at pos, if there is one.
+func (l *Level) FeatureAt(pos GridPos) (Feature, bool) {
+	f, ok := l.features[pos]
+	if !ok {
+		return Feature{}, false
+	}
+	f.Open = f.Open != l.flipped[pos]
+	return f, true
+}
+
+// Features returns the positions of every interactive tile in reading order.
+func (l *Level) Features() []GridPos {
+	out := slices.Collect(maps.Keys(l.features))
+	slices.SortFunc(out, compareGridPos)
+	return out
+}
+
+// OnTileChange registers fn to be called whenever a door, gate or other
+// dynamic tile changes type. Cached paths are already invalid when it runs.
+func (l *Level) OnTileChange(fn func(TileChange)) {
+	l.tileListeners = append(l.tileListeners, fn)
+}
+
+// Revision counts tile changes, so callers that keep state across levels can
+// notice them by comparing it instead of subscribing to each level.
+func (l *Level) Revision() uint64 {
+	return l.revision
+}
+
+// CollectKey picks up the key at pos and unlocks the doors it belongs to.
+// It reports whether there was a key to collect.
+func (l *Level) CollectKey(pos GridPos) bool {
+	f, ok := l.FeatureAt(pos)
+	if !ok || f.Kind != FeatureKey || f.Open {
+		return false
+	}
+	l.flip(pos)
+	for _, door := range l.Features() {
+		if d, _ := l.FeatureAt(door); d.Kind == FeatureDoor && d.Key == f.Key && !d.Open {
+			l.flip(door)
+		}
+	}
+	return true
+}
+
+// PressSwitch toggles the gates in the group of the switch at pos and
+// reports whether there was a switch. Gates never close on a tile blocked
+// reports as occupied; they stay open instead.
+func (l *Level) PressSwitch(pos GridPos, blocked func(GridPos) bool) bool {
+	f, ok := l.FeatureAt(pos)
+	if !ok || f.Kind != FeatureSwitch {
+		return false
+	}
+	for _, gate := range l.Features() {
+		g, _ := l.FeatureAt(gate)
+		if g.Kind != FeatureGate || g.Group != f.Group {
+			continue
+		}
+		if g.Open && blocked != nil && blocked(gate) {
+			continue
+		}
+		l.flip(gate)
+	}
+	return true
+}
+
+// flip inverts the state of the feature at pos and updates its tile.
+func (l *Level) flip(pos GridPos) {
+	if l.flipped[pos] {
+		delete(l.flipped, pos)
+	} else {
+		l.flipped[pos] = true
+	}
+	l.syncFeatureTile(pos)
+}
+
+// syncFeatureTile makes the tile under a feature match its state.
+func (l *Level) syncFeatureTile(pos GridPos) {
+	f, _ := l.FeatureAt(pos)
+	tile := TilePath
+	if f.solid() {
+		tile = TileWall
+	}
+	if l.Tiles[pos.Row][pos.Col] == tile {
+		return
+	}
+	l.Tiles[pos.Row][pos.Col] = tile
+	l.walkable = l.walkable[:0]
+	for row := range l.Tiles {
+		for col, t := range l.Tiles[row] {
+			if t != TileWall {
+				l.walkable = append(l.walkable, GridPos{Col: col, Row: row})
+			}
+		}
+	}
+	l.invalidatePaths()
+	l.revision++
+	for _, fn := range l.tileListeners {
+		fn(TileChange{Pos: pos, Tile: tile})
+	}
+}
+
+// setFlipped replaces the changed-feature set, e.g. when a snapshot is restored.
+func (l *Level) setFlipped(flipped map[GridPos]bool) {
+	l.flipped = map[GridPos]bool{}
+	for pos, on := range flipped {
+		if on && l.features[pos].Kind != FeatureNone {
+			l.flipped[pos] = true
+		}
+	}
+	for pos := range l.features {
+		l.syncFeatureTile(pos)
+	}
+}
+
+// SetChangedFeatures applies a list from PelletState.ChangedFeatures to a
+// level in its original state, as when loading a save.
+func (l *Level) SetChangedFeatures(changed []GridPos) {
+	flipped := make(map[GridPos]bool, len(changed))
+	for _, pos := range changed {
+		flipped[pos] = true
+	}
+	l.setFlipped(flipped)
+}
+
+// ChangedFeatures lists the interactive tiles whose state differs from the
+// layout: collected keys, unlocked doors and toggled gates.
+func (s PelletState) ChangedFeatures() []GridPos {
+	out := slices.Collect(maps.Keys(s.flipped))
+	slices.SortFunc(out, compareGridPos)
+	return out
+}
+
+// StateLayout is Layout with every interactive tile drawn in its current
+// state, so the level can be rebuilt elsewhere as it is now.
+func (l *Level) StateLayout() []string {
+	layout := l.Layout()
+	for pos := range l.flipped {
+		f, _ := l.FeatureAt(pos)
+		var ch byte
+		switch {
+		case f.Kind == FeatureGate && f.Open:
+			ch = '_'
+		case f.Kind == FeatureGate:
+			ch = '%'
+		default:
+			// Collected keys and unlocked doors are plain path from now on.
+			ch = ' '
+		}
+		row := []byte(layout[pos.Row])
+		row[pos.Col] = ch
+		layout[pos.Row] = string(row)
+	}
+	return layout
+}
+
+// groupFeatures applies group directives to the switches and gates they name.
+func (l *Level) groupFeatures(d directives) []Issue {
+	var issues []Issue
+	for pos, group := range d.groups {
+		f, ok := l.features[pos]
+		if !ok || (f.Kind != FeatureSwitch && f.Kind != FeatureGate) {
+			issues = append(issues, tileIssue(pos, SeverityError, "group-not-switch",
+				"group directive names a tile that is not a switch or gate"))
+			continue
+		}
+		f.Group = group
+		l.features[pos] = f
+	}
+	return issues
+}
+
+// featureIssues reports keys and doors without a partner and switches and
+// gates alone in their group.
+func (l *Level) featureIssues() []Issue {
+	var issues []Issue
+	keys, doors := map[byte]bool{}, map[byte]bool{}
+	switches, gates := map[string]bool{}, map[string]bool{}
+	for _, f := range l.features {
+		switch f.Kind {
+		case FeatureKey:
+			keys[f.Key] = true
+		case FeatureDoor:
+			doors[f.Key] = true
+		case FeatureSwitch:
+			switches[f.Group] = true
+		case FeatureGate:
+			gates[f.Group] = true
+		}
+	}
+	for _, pos := range l.Features() {
+		f := l.features[pos]
+		switch {
+		case f.Kind == FeatureDoor && !keys[f.Key]:
+			issues = append(issues, tileIssue(pos, SeverityWarning, "door-without-key", "no key opens this door"))
+		case f.Kind == FeatureKey && !doors[f.Key]:
+			issues = append(issues, tileIssue(pos, SeverityWarning, "key-without-door", "this key opens no door"))
+		case f.Kind == FeatureSwitch && !gates[f.Group]:
+			issues = append(issues, tileIssue(pos, SeverityWarning, "switch-without-gate", "no gate shares this switch's group"))
+		case f.Kind == FeatureGate && !switches[f.Group]:
+			issues = append(issues, tileIssue(pos, SeverityWarning, "gate-without-switch", "no switch shares this gate's group"))
+		}
+	}
+	return issues
+}
+
+// openFeatures opens every door and gate, for checks that must treat them as passable.
+func (l *Level) openFeatures() {
+	flipped := map[GridPos]bool{}
+	for pos, f := range l.features {
+		if f.solid() {
+			flipped[pos] = true
+		}
+	}
+	l.setFlipped(flipped)
+}
+
+func compareGridPos(a, b GridPos) int {
+	if a.Row != b.Row {
+		return a.Row - b.Row
+	}
+	return a.Col - b.Col
+}
@@ -0,0 +1,37 @@
+package level
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPressSwitchNotifiesTileChange(t *testing.T) {
+	l, err := New([]string{"#######", "#P+.%.#", "#######"}, DefaultTileSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spawn, sw := GridPos{Col: 1, Row: 1}, GridPos{Col: 2, Row: 1}
+	gate, beyond := GridPos{Col: 4, Row: 1}, GridPos{Col: 5, Row: 1}
+	// Cache the field before the gate moves, so a stale one would show.
+	if d := l.DistanceField(beyond).At(spawn); d != Unreachable {
+		t.Fatalf("closed gate lets the spawn reach %v in %d steps", beyond, d)
+	}
+
+	type notice struct {
+		change TileChange
+		steps  int
+	}
+	var got []notice
+	l.OnTileChange(func(c TileChange) {
+		got = append(got, notice{c, l.DistanceField(beyond).At(spawn)})
+	})
+	l.PressSwitch(sw, nil)
+	l.PressSwitch(sw, nil)
+	want := []notice{
+		{TileChange{Pos: gate, Tile: TilePath}, 4},
+		{TileChange{Pos: gate, Tile: TileWall}, Unreachable},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("notices = %+v, want %+v", got, want)
+	}
+}
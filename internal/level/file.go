@@ -0,0 +1,57 @@
+package level
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// filePrefix marks level IDs that refer to a layout file on disk.
+const filePrefix = "file:"
+
+// ReadLayout reads layout rows from r, one per line. Carriage returns and
+// trailing blank lines are dropped so files edited on any platform load alike.
+func ReadLayout(r io.Reader) ([]string, error) {
+	var rows []string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		rows = append(rows, strings.TrimRight(sc.Text(), "\r"))
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	for len(rows) > 0 && strings.TrimSpace(rows[len(rows)-1]) == "" {
+		rows = rows[:len(rows)-1]
+	}
+	return rows, nil
+}
+
+// ReadLayoutFile reads the layout rows stored at path.
+func ReadLayoutFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadLayout(f)
+}
+
+// LoadFile builds a level from a layout file, or from a Tiled map when the
+// extension says so. Its ID points back at the file so saves can rebuild it.
+func LoadFile(path string) (*Level, error) {
+	if IsTiledFile(path) {
+		return LoadTiled(path)
+	}
+	layout, err := ReadLayoutFile(path)
+	if err != nil {
+		return nil, err
+	}
+	l, err := New(layout, DefaultTileSize)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	l.ID = filePrefix + path
+	return l, nil
+}
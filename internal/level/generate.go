@@ -0,0 +1,411 @@
+package level
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sky0621/koro/internal/rng"
+)
+
+// generatedPrefix marks level IDs produced by Generate with default params.
+const generatedPrefix = "gen:"
+
+// GenerateParams shapes the mazes built by Generate. Width and Height count
+// tiles and must be odd; mirrored mazes also need Width%4 == 3 so the centre
+// column is a corridor both halves share.
+type GenerateParams struct {
+	Width, Height int
+	// Symmetric mirrors the left half onto the right, like the arcade maze.
+	Symmetric bool
+	// AllowDeadEnds keeps the dead ends a spanning tree leaves behind.
+	AllowDeadEnds bool
+	// LoopDensity is the chance, from 0 to 1, of opening each wall left
+	// between two corridors once the maze is connected.
+	LoopDensity float64
+	// GhostHouse reserves a walled pen with one door in the middle of the maze.
+	GhostHouse bool
+	// Tunnels is the number of warp tunnels cut through the side walls.
+	Tunnels int
+}
+
+// DefaultGenerateParams returns the settings used for generated level IDs.
+func DefaultGenerateParams() GenerateParams {
+	return GenerateParams{
+		Width:       19,
+		Height:      21,
+		Symmetric:   true,
+		LoopDensity: 0.15,
+		GhostHouse:  true,
+		Tunnels:     1,
+	}
+}
+
+// GeneratedID returns the level ID that rebuilds Generate(seed, DefaultGenerateParams()).
+func GeneratedID(seed int64) string {
+	return generatedPrefix + strconv.FormatInt(seed, 10)
+}
+
+// DailyID returns the generated level shared by everyone on the given day.
+func DailyID(day time.Time) string {
+	y, m, d := day.Date()
+	return GeneratedID(int64(y*10000 + int(m)*100 + d))
+}
+
+func generated(id string) (*Level, error) {
+	seed, err := strconv.ParseInt(strings.TrimPrefix(id, generatedPrefix), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid generated level %q: %w", id, err)
+	}
+	layout, err := Generate(seed, DefaultGenerateParams())
+	if err != nil {
+		return nil, err
+	}
+	l, err := New(layout, DefaultTileSize)
+	if err != nil {
+		return nil, err
+	}
+	l.ID = id
+	return l, nil
+}
+
+// house is the size of the ghost pen including its walls.
+const (
+	houseWidth  = 7
+	houseHeight = 5
+)
+
+// Generate builds a maze layout for New. Corridors run along odd rows and
+// columns: a randomised depth-first search carves a spanning tree, so every
+// tile is reachable, then loops, the ghost house and tunnels are added and
+// dead ends are opened up. The same seed and params always give the same maze.
+func Generate(seed int64, params GenerateParams) ([]string, error) {
+	if err := params.check(); err != nil {
+		return nil, err
+	}
+	r, _ := rng.New(seed)
+	m := newMazeGrid(params, r)
+
+	m.carveTree()
+	m.addLoops()
+	if params.GhostHouse {
+		m.placeHouse()
+	}
+	m.connect()
+	if !params.AllowDeadEnds {
+		m.removeDeadEnds()
+	}
+	m.cutTunnels()
+
+	layout := m.layout()
+	for _, issue := range Validate(layout) {
+		if issue.Severity == SeverityError {
+			return nil, fmt.Errorf("generated maze is invalid: %w", issue)
+		}
+	}
+	return layout, nil
+}
+
+func (p GenerateParams) check() error {
+	switch {
+	case p.Width%2 == 0 || p.Height%2 == 0:
+		return fmt.Errorf("maze size %dx%d must be odd", p.Width, p.Height)
+	case p.Width < 11 || p.Height < 11:
+		return fmt.Errorf("maze size %dx%d is below the 11x11 minimum", p.Width, p.Height)
+	case p.Symmetric && p.Width%4 != 3:
+		return fmt.Errorf("mirrored maze width %d must leave a remainder of 3 when divided by 4", p.Width)
+	case p.LoopDensity < 0 || p.LoopDensity > 1:
+		return fmt.Errorf("loop density %v is outside 0..1", p.LoopDensity)
+	case p.Tunnels < 0 || p.Tunnels > 10:
+		return fmt.Errorf("tunnel count %d is outside 0..10", p.Tunnels)
+	}
+	return nil
+}
+
+// mazeGrid is the working state of Generate. Tiles at odd row and column are
+// cells; the tiles between two cells are the walls that carving opens.
+type mazeGrid struct {
+	params GenerateParams
+	r      *rand.Rand
+	width  int
+	height int
+	open   [][]bool
+	// house marks the pen and its walls, which the maze passes must not touch.
+	house  [][]bool
+	spawn  GridPos
+	warps  []GridPos
+	ghosts []GridPos
+}
+
+func newMazeGrid(params GenerateParams, r *rand.Rand) *mazeGrid {
+	m := &mazeGrid{
+		params: params,
+		r:      r,
+		width:  params.Width,
+		height: params.Height,
+		open:   make([][]bool, params.Height),
+		house:  make([][]bool, params.Height),
+	}
+	for row := range m.open {
+		m.open[row] = make([]bool, params.Width)
+		m.house[row] = make([]bool, params.Width)
+	}
+	return m
+}
+
+var mazeSteps = [...]GridPos{{0, -1}, {0, 1}, {-1, 0}, {1, 0}}
+
+// centerCol is the cell column closest to the middle of the maze.
+func (m *mazeGrid) centerCol() int {
+	c := (m.width - 1) / 2
+	if c%2 == 0 {
+		c--
+	}
+	return c
+}
+
+// carve opens a tile and, in mirrored mazes, its reflection.
+func (m *mazeGrid) carve(pos GridPos) {
+	m.open[pos.Row][pos.Col] = true
+	if m.params.Symmetric {
+		m.open[pos.Row][m.width-1-pos.Col] = true
+	}
+}
+
+func (m *mazeGrid) isCell(pos GridPos) bool {
+	return pos.Col%2 == 1 && pos.Row%2 == 1 &&
+		pos.Col > 0 && pos.Row > 0 && pos.Col < m.width-1 && pos.Row < m.height-1
+}
+
+// editable reports whether a pass may work on pos: the left half of a
+// mirrored maze, anywhere otherwise, and never inside the ghost house.
+func (m *mazeGrid) editable(pos GridPos) bool {
+	if !m.isInterior(pos) || m.house[pos.Row][pos.Col] {
+		return false
+	}
+	return !m.params.Symmetric || pos.Col <= (m.width-1)/2
+}
+
+func (m *mazeGrid) isInterior(pos GridPos) bool {
+	return pos.Col > 0 && pos.Row > 0 && pos.Col < m.width-1 && pos.Row < m.height-1
+}
+
+func (m *mazeGrid) isOpen(pos GridPos) bool {
+	return pos.Row >= 0 && pos.Row < m.height && pos.Col >= 0 && pos.Col < m.width && m.open[pos.Row][pos.Col]
+}
+
+// cellNeighbors returns the cells two tiles away from pos with the wall between.
+func (m *mazeGrid) cellNeighbors(pos GridPos) (cells, walls []GridPos) {
+	for _, d := range mazeSteps {
+		cell := GridPos{Col: pos.Col + 2*d.Col, Row: pos.Row + 2*d.Row}
+		wall := GridPos{Col: pos.Col + d.Col, Row: pos.Row + d.Row}
+		if m.isCell(cell) && m.editable(wall) && !m.house[cell.Row][cell.Col] {
+			cells = append(cells, cell)
+			walls = append(walls, wall)
+		}
+	}
+	return cells, walls
+}
+
+// carveTree runs a randomised depth-first search over the editable cells.
+func (m *mazeGrid) carveTree() {
+	start := GridPos{Col: 1, Row: 1}
+	visited := map[GridPos]bool{start: true}
+	stack := []GridPos{start}
+	m.carve(start)
+	for len(stack) > 0 {
+		cur := stack[len(stack)-1]
+		cells, walls := m.cellNeighbors(cur)
+		var options []int
+		for i, cell := range cells {
+			if !visited[cell] && m.editable(cell) {
+				options = append(options, i)
+			}
+		}
+		if len(options) == 0 {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		i := options[m.r.Intn(len(options))]
+		visited[cells[i]] = true
+		m.carve(walls[i])
+		m.carve(cells[i])
+		stack = append(stack, cells[i])
+	}
+}
+
+// addLoops opens each remaining wall between two cells with LoopDensity odds.
+func (m *mazeGrid) addLoops() {
+	if m.params.LoopDensity == 0 {
+		return
+	}
+	for row := 1; row < m.height-1; row++ {
+		for col := 1; col < m.width-1; col++ {
+			pos := GridPos{Col: col, Row: row}
+			if (row+col)%2 == 1 && m.editable(pos) && !m.isOpen(pos) && m.r.Float64() < m.params.LoopDensity {
+				m.carve(pos)
+			}
+		}
+	}
+}
+
+// placeHouse stamps the ghost pen in the middle, wraps it in a corridor and
+// opens a door in its top wall. The pen's walls sit on even rows and columns
+// so the surrounding corridor lines up with the cells.
+func (m *mazeGrid) placeHouse() {
+	cx := m.centerCol()
+	top := (m.height - houseHeight) / 2
+	if top%2 == 1 {
+		top--
+	}
+	left := cx - houseWidth/2
+	for row := top - 1; row <= top+houseHeight; row++ {
+		for col := left - 1; col <= left+houseWidth; col++ {
+			ring := row == top-1 || row == top+houseHeight || col == left-1 || col == left+houseWidth
+			if ring {
+				m.carve(GridPos{Col: col, Row: row})
+				continue
+			}
+			m.house[row][col] = true
+			wall := row == top || row == top+houseHeight-1 || col == left || col == left+houseWidth-1
+			m.open[row][col] = !wall
+		}
+	}
+	m.open[top][cx] = true
+	m.ghosts = []GridPos{{Col: cx - 1, Row: top + 2}, {Col: cx, Row: top + 2}, {Col: cx + 1, Row: top + 2}}
+	m.spawn = GridPos{Col: cx, Row: top + houseHeight}
+}
+
+// connect joins any cells the earlier passes cut off by opening a wall
+// between them and the region reachable from the first cell.
+func (m *mazeGrid) connect() {
+	for {
+		reached := m.reachable(GridPos{Col: 1, Row: 1})
+		joined := false
+		for row := 1; row < m.height-1 && !joined; row += 2 {
+			for col := 1; col < m.width-1 && !joined; col += 2 {
+				cell := GridPos{Col: col, Row: row}
+				if reached[cell] || m.house[row][col] {
+					continue
+				}
+				cells, walls := m.cellNeighbors(cell)
+				for i, next := range cells {
+					if reached[next] {
+						m.carve(cell)
+						m.carve(walls[i])
+						joined = true
+						break
+					}
+				}
+			}
+		}
+		if !joined {
+			return
+		}
+	}
+}
+
+func (m *mazeGrid) reachable(from GridPos) map[GridPos]bool {
+	seen := map[GridPos]bool{from: true}
+	queue := []GridPos{from}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, d := range mazeSteps {
+			next := GridPos{Col: cur.Col + d.Col, Row: cur.Row + d.Row}
+			if m.isOpen(next) && !seen[next] {
+				seen[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	return seen
+}
+
+func (m *mazeGrid) degree(pos GridPos) int {
+	n := 0
+	for _, d := range mazeSteps {
+		if m.isOpen(GridPos{Col: pos.Col + d.Col, Row: pos.Row + d.Row}) {
+			n++
+		}
+	}
+	return n
+}
+
+// removeDeadEnds opens an extra wall at every cell with a single exit.
+func (m *mazeGrid) removeDeadEnds() {
+	for row := 1; row < m.height-1; row += 2 {
+		for col := 1; col < m.width-1; col += 2 {
+			cell := GridPos{Col: col, Row: row}
+			if !m.editable(cell) || m.degree(cell) != 1 {
+				continue
+			}
+			_, walls := m.cellNeighbors(cell)
+			var closed []GridPos
+			for _, wall := range walls {
+				if !m.isOpen(wall) {
+					closed = append(closed, wall)
+				}
+			}
+			if len(closed) > 0 {
+				m.carve(closed[m.r.Intn(len(closed))])
+			}
+		}
+	}
+}
+
+// cutTunnels opens warp pairs through the side walls on distinct cell rows,
+// away from the ghost house.
+func (m *mazeGrid) cutTunnels() {
+	var rows []int
+	for row := 1; row < m.height-1; row += 2 {
+		if !m.house[row][m.centerCol()] && m.open[row][1] && m.open[row][m.width-2] {
+			rows = append(rows, row)
+		}
+	}
+	m.r.Shuffle(len(rows), func(i, j int) { rows[i], rows[j] = rows[j], rows[i] })
+	for _, row := range rows[:min(m.params.Tunnels, len(rows))] {
+		m.warps = append(m.warps, GridPos{Col: 0, Row: row}, GridPos{Col: m.width - 1, Row: row})
+	}
+}
+
+// layout renders the grid in the rune format New reads. Tunnels get digit
+// labels so each pair is explicit; cell corners hold the power pellets.
+func (m *mazeGrid) layout() []string {
+	rows := make([][]byte, m.height)
+	for row := range rows {
+		rows[row] = make([]byte, m.width)
+		for col := range rows[row] {
+			switch {
+			case !m.open[row][col]:
+				rows[row][col] = '#'
+			case m.house[row][col]:
+				rows[row][col] = ' '
+			default:
+				rows[row][col] = '.'
+			}
+		}
+	}
+	for _, corner := range [...]GridPos{{1, 1}, {m.width - 2, 1}, {1, m.height - 2}, {m.width - 2, m.height - 2}} {
+		rows[corner.Row][corner.Col] = 'o'
+	}
+	for i, w := range m.warps {
+		rows[w.Row][w.Col] = byte('0' + i/2)
+	}
+	for _, g := range m.ghosts {
+		rows[g.Row][g.Col] = 'G'
+	}
+	spawn := m.spawn
+	if !m.params.GhostHouse {
+		spawn = GridPos{Col: m.centerCol(), Row: (m.height * 3 / 4) | 1}
+	}
+	rows[spawn.Row][spawn.Col] = 'P'
+
+	out := make([]string, m.height)
+	for i, row := range rows {
+		out[i] = string(row)
+	}
+	return out
+}
@@ -0,0 +1,138 @@
+package level
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+var generateCases = []struct {
+	name   string
+	params GenerateParams
+}{
+	{"default", DefaultGenerateParams()},
+	{"no dead ends", GenerateParams{Width: 23, Height: 17, Symmetric: true, LoopDensity: 0.1, GhostHouse: true, Tunnels: 2}},
+	{"asymmetric", GenerateParams{Width: 21, Height: 15, LoopDensity: 0.3, GhostHouse: true, Tunnels: 1}},
+	{"tree", GenerateParams{Width: 11, Height: 11, AllowDeadEnds: true}},
+	{"asymmetric without dead ends", GenerateParams{Width: 25, Height: 19, Tunnels: 3}},
+}
+
+const generateSeeds = 25
+
+func TestGenerateIsDeterministic(t *testing.T) {
+	for _, tt := range generateCases {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := Generate(7, tt.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := Generate(7, tt.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(a, b) {
+				t.Errorf("same seed, different mazes:\n%s\n\n%s", strings.Join(a, "\n"), strings.Join(b, "\n"))
+			}
+			if c, err := Generate(8, tt.params); err == nil && slices.Equal(a, c) {
+				t.Errorf("seeds 7 and 8 gave the same maze")
+			}
+		})
+	}
+}
+
+func TestGenerateLayouts(t *testing.T) {
+	for _, tt := range generateCases {
+		t.Run(tt.name, func(t *testing.T) {
+			for seed := range int64(generateSeeds) {
+				layout, err := Generate(seed, tt.params)
+				if err != nil {
+					t.Fatalf("seed %d: %v", seed, err)
+				}
+				if _, err := New(layout, DefaultTileSize); err != nil {
+					t.Fatalf("seed %d: New: %v", seed, err)
+				}
+				if len(layout) != tt.params.Height || len(layout[0]) != tt.params.Width {
+					t.Fatalf("seed %d: maze is %dx%d", seed, len(layout[0]), len(layout))
+				}
+				if tt.params.Symmetric {
+					checkMirrored(t, seed, layout)
+				}
+				if !tt.params.AllowDeadEnds {
+					checkNoDeadEnds(t, seed, layout)
+				}
+			}
+		})
+	}
+}
+
+// checkMirrored fails unless every row reads the same walls backwards.
+func checkMirrored(t *testing.T, seed int64, layout []string) {
+	t.Helper()
+	for row, line := range layout {
+		for col := range len(line) / 2 {
+			if (line[col] == '#') != (line[len(line)-1-col] == '#') {
+				t.Fatalf("seed %d: row %d is not mirrored: %s", seed, row, line)
+			}
+		}
+	}
+}
+
+// checkNoDeadEnds fails on any corridor tile with a single way out. The
+// ghost house is a pen with one door, so its tiles do not count.
+func checkNoDeadEnds(t *testing.T, seed int64, layout []string) {
+	t.Helper()
+	walkable := func(col, row int) bool {
+		return row >= 0 && row < len(layout) && col >= 0 && col < len(layout[row]) && layout[row][col] != '#'
+	}
+	for row, line := range layout {
+		for col, ch := range line {
+			if ch == '#' || ch == ' ' || ch == 'G' || (ch >= '0' && ch <= '9') {
+				continue
+			}
+			exits := 0
+			for _, d := range mazeSteps {
+				if walkable(col+d.Col, row+d.Row) {
+					exits++
+				}
+			}
+			if exits == 1 {
+				t.Fatalf("seed %d: dead end at %d,%d:\n%s", seed, col, row, strings.Join(layout, "\n"))
+			}
+		}
+	}
+}
+
+func TestGenerateParamsCheck(t *testing.T) {
+	valid := DefaultGenerateParams()
+	tests := []struct {
+		name   string
+		change func(p *GenerateParams)
+		want   string
+	}{
+		{"even width", func(p *GenerateParams) { p.Width = 20 }, "must be odd"},
+		{"even height", func(p *GenerateParams) { p.Height = 22 }, "must be odd"},
+		{"too narrow", func(p *GenerateParams) { p.Width, p.Symmetric = 9, false }, "below the 11x11 minimum"},
+		{"too short", func(p *GenerateParams) { p.Height = 9 }, "below the 11x11 minimum"},
+		{"mirrored width", func(p *GenerateParams) { p.Width = 21 }, "remainder of 3"},
+		{"negative density", func(p *GenerateParams) { p.LoopDensity = -0.1 }, "outside 0..1"},
+		{"density above one", func(p *GenerateParams) { p.LoopDensity = 1.5 }, "outside 0..1"},
+		{"negative tunnels", func(p *GenerateParams) { p.Tunnels = -1 }, "outside 0..10"},
+		{"too many tunnels", func(p *GenerateParams) { p.Tunnels = 11 }, "outside 0..10"},
+	}
+	if err := valid.check(); err != nil {
+		t.Fatalf("default params rejected: %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := valid
+			tt.change(&p)
+			err := p.check()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("check() = %v, want it to mention %q", err, tt.want)
+			}
+			if _, err := Generate(1, p); err == nil {
+				t.Error("Generate accepted the params")
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package level
+
+// NodeKind classifies the decision points of a maze.
+type NodeKind int
+
+const (
+	// NodeJunction is a tile with three or more exits.
+	NodeJunction NodeKind = iota
+	// NodeDeadEnd is a tile with a single exit.
+	NodeDeadEnd
+	// NodeLoop anchors a corridor loop that has no junction on it.
+	NodeLoop
+	// NodeIsolated is a walkable tile with no exits at all.
+	NodeIsolated
+)
+
+// Node is a decision point in the navigation graph.
+type Node struct {
+	Pos  GridPos
+	Kind NodeKind
+	// Edges indexes into Graph.Edges for every corridor leaving this node.
+	Edges []int
+}
+
+// Edge is a corridor between two nodes. Corners along the way are not nodes
+// because actors have no choice to make there.
+type Edge struct {
+	From, To int
+	// Weight is the corridor length in steps.
+	Weight int
+	// Path lists every tile from the From node to the To node inclusive.
+	Path []GridPos
+}
+
+// Graph is the junction/corridor graph of a level, built on first use and
+// again whenever a door or gate changes the maze.
+type Graph struct {
+	Nodes []Node
+	Edges []Edge
+	index map[GridPos]int
+}
+
+// Graph returns the navigation graph of the level.
+func (l *Level) Graph() *Graph {
+	if l.graph == nil {
+		l.graph = buildGraph(l)
+	}
+	return l.graph
+}
+
+// NodeAt returns the node index at pos, if pos is a decision point.
+func (g *Graph) NodeAt(pos GridPos) (int, bool) {
+	idx, ok := g.index[pos]
+	return idx, ok
+}
+
+// IsDecisionPoint reports whether actors passing pos can choose between exits
+// (or must turn back): junctions and dead ends.
+func (g *Graph) IsDecisionPoint(pos GridPos) bool {
+	idx, ok := g.index[pos]
+	if !ok {
+		return false
+	}
+	kind := g.Nodes[idx].Kind
+	return kind == NodeJunction || kind == NodeDeadEnd
+}
+
+// Neighbors returns the edges leaving a node along with the node on the far end.
+func (g *Graph) Neighbors(node int) []Edge {
+	out := make([]Edge, 0, len(g.Nodes[node].Edges))
+	for _, e := range g.Nodes[node].Edges {
+		edge := g.Edges[e]
+		if edge.From != node {
+			edge = edge.reversed()
+		}
+		out = append(out, edge)
+	}
+	return out
+}
+
+func (e Edge) reversed() Edge {
+	path := make([]GridPos, len(e.Path))
+	for i, p := range e.Path {
+		path[len(path)-1-i] = p
+	}
+	return Edge{From: e.To, To: e.From, Weight: e.Weight, Path: path}
+}
+
+func buildGraph(l *Level) *Graph {
+	g := &Graph{index: map[GridPos]int{}}
+	for _, pos := range l.walkable {
+		switch n := len(l.Neighbors(pos)); {
+		case n == 0:
+			g.addNode(pos, NodeIsolated)
+		case n == 1:
+			g.addNode(pos, NodeDeadEnd)
+		case n >= 3:
+			g.addNode(pos, NodeJunction)
+		}
+	}
+
+	covered := map[GridPos]bool{}
+	traced := map[[2]GridPos]bool{}
+	for i := 0; i < len(g.Nodes); i++ {
+		g.traceFrom(l, i, covered, traced)
+	}
+	// Whatever is left are loops with no junction; anchor each with a node.
+	for _, pos := range l.walkable {
+		if covered[pos] {
+			continue
+		}
+		if _, ok := g.index[pos]; ok {
+			continue
+		}
+		idx := g.addNode(pos, NodeLoop)
+		g.traceFrom(l, idx, covered, traced)
+	}
+	return g
+}
+
+func (g *Graph) addNode(pos GridPos, kind NodeKind) int {
+	g.index[pos] = len(g.Nodes)
+	g.Nodes = append(g.Nodes, Node{Pos: pos, Kind: kind})
+	return len(g.Nodes) - 1
+}
+
+// traceFrom follows every untraced corridor leaving a node until it reaches
+// another node. traced remembers (node, first step) pairs so each corridor is
+// only walked once, from whichever end gets there first.
+func (g *Graph) traceFrom(l *Level, from int, covered map[GridPos]bool, traced map[[2]GridPos]bool) {
+	start := g.Nodes[from].Pos
+	covered[start] = true
+	for _, first := range l.Neighbors(start) {
+		if traced[[2]GridPos{start, first}] {
+			continue
+		}
+		path := []GridPos{start}
+		onPath := map[GridPos]bool{start: true}
+		prev, cur := start, first
+		for {
+			path = append(path, cur)
+			if onPath[cur] {
+				// A one-way warp led into a ring with no junction on it;
+				// anchor the ring where the walk closed it.
+				g.addNode(cur, NodeLoop)
+			}
+			onPath[cur] = true
+			if _, ok := g.index[cur]; ok {
+				break
+			}
+			covered[cur] = true
+			next, ok := corridorNext(l, cur, prev)
+			if !ok {
+				break
+			}
+			prev, cur = cur, next
+		}
+		to := g.index[cur]
+		traced[[2]GridPos{start, first}] = true
+		traced[[2]GridPos{cur, prev}] = true
+		g.Edges = append(g.Edges, Edge{From: from, To: to, Weight: len(path) - 1, Path: path})
+		e := len(g.Edges) - 1
+		g.Nodes[from].Edges = append(g.Nodes[from].Edges, e)
+		if to != from {
+			g.Nodes[to].Edges = append(g.Nodes[to].Edges, e)
+		}
+	}
+}
+
+// corridorNext returns the exit of a two-way corridor tile other than the one we came from.
+func corridorNext(l *Level, cur, prev GridPos) (GridPos, bool) {
+	for _, n := range l.Neighbors(cur) {
+		if n != prev {
+			return n, true
+		}
+	}
+	return GridPos{}, false
+}
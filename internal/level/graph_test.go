@@ -0,0 +1,77 @@
+package level
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGraphCoversEveryTile(t *testing.T) {
+	tests := []struct {
+		name   string
+		layout []string
+		// loop is where a NodeLoop must anchor, if anywhere.
+		loop *GridPos
+	}{
+		{
+			name:   "corridor between dead ends",
+			layout: []string{"#######", "#P....#", "#######"},
+		},
+		{
+			name:   "ring without a junction",
+			layout: []string{"#####", "#P..#", "#.#.#", "#...#", "#####"},
+			loop:   &GridPos{Col: 1, Row: 1},
+		},
+		{
+			name: "one-way warp into a ring",
+			layout: []string{
+				"#########",
+				"#P.1#...#",
+				"#####.#.#",
+				"#####.2.#",
+				"#########",
+				"---",
+				"warp 1 -> 2",
+			},
+			loop: &GridPos{Col: 6, Row: 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			built := make(chan *Level, 1)
+			go func() {
+				l, err := New(tt.layout, DefaultTileSize)
+				if err != nil {
+					t.Error(err)
+				}
+				built <- l
+			}()
+			var l *Level
+			select {
+			case l = <-built:
+			case <-time.After(5 * time.Second):
+				t.Fatal("building the graph did not finish")
+			}
+			if l == nil {
+				return
+			}
+			g := l.Graph()
+			if tt.loop != nil {
+				idx, ok := g.NodeAt(*tt.loop)
+				if !ok || g.Nodes[idx].Kind != NodeLoop {
+					t.Fatalf("no loop node at %v: %+v", *tt.loop, g.Nodes)
+				}
+			}
+			for _, pos := range l.WalkableTiles() {
+				found := false
+				for _, e := range g.Edges {
+					for _, p := range e.Path {
+						found = found || p == pos
+					}
+				}
+				if !found {
+					t.Errorf("tile %v is on no edge", pos)
+				}
+			}
+		})
+	}
+}
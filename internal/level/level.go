@@ -2,7 +2,10 @@ package level
 
 import (
 	"fmt"
+	"maps"
 	"math"
+	"strconv"
+	"strings"
 )
 
 // TileType represents the type of a tile within the level map.
@@ -21,24 +24,94 @@ const (
 	PelletNone PelletType = iota
 	PelletSmall
 	PelletPower
+	// PelletSpeed ('!') speeds the player up for a while.
+	PelletSpeed
+	// PelletFreeze ('*') stops every ghost for a while.
+	PelletFreeze
+	// PelletMagnet ('?') pulls in the pellets around the player for a while.
+	PelletMagnet
+	// PelletMultiplier ('$') doubles the points scored for a while.
+	PelletMultiplier
+	// PelletShield ('@') absorbs the next ghost that catches the player.
+	PelletShield
 )
 
+// pelletRunes maps the layout runes that place a pellet to its type.
+var pelletRunes = map[rune]PelletType{
+	'.': PelletSmall,
+	'o': PelletPower,
+	'!': PelletSpeed,
+	'*': PelletFreeze,
+	'?': PelletMagnet,
+	'$': PelletMultiplier,
+	'@': PelletShield,
+}
+
 // GridPos holds column/row coordinates in the tile map.
 type GridPos struct {
 	Col int
 	Row int
 }
 
+// MarshalText encodes the position as "col,row" so it can key JSON maps.
+func (p GridPos) MarshalText() ([]byte, error) {
+	return []byte(strconv.Itoa(p.Col) + "," + strconv.Itoa(p.Row)), nil
+}
+
+// UnmarshalText parses the "col,row" form produced by MarshalText.
+func (p *GridPos) UnmarshalText(text []byte) error {
+	colText, rowText, ok := strings.Cut(string(text), ",")
+	if !ok {
+		return fmt.Errorf("invalid grid position %q", text)
+	}
+	col, err := strconv.Atoi(colText)
+	if err != nil {
+		return fmt.Errorf("invalid grid column %q: %w", colText, err)
+	}
+	row, err := strconv.Atoi(rowText)
+	if err != nil {
+		return fmt.Errorf("invalid grid row %q: %w", rowText, err)
+	}
+	p.Col, p.Row = col, row
+	return nil
+}
+
+const (
+	// DefaultID identifies the built-in stage.
+	DefaultID = "default"
+	// DefaultTileSize is the tile edge in pixels used by built-in and file levels.
+	DefaultTileSize = 16
+)
+
 // Level contains tile data and warp links for a stage.
 type Level struct {
+	// ID names the stage so saves can rebuild it; empty for ad-hoc layouts.
+	ID           string
 	Tiles        [][]TileType
 	TileSize     int
 	Width        int
 	Height       int
-	warpTargets  map[GridPos]GridPos
+	warps        map[GridPos]Warp
+	warpSources  map[GridPos][]GridPos
 	pellets      [][]PelletType
+	modifiers    [][]Modifier
 	totalPellets int
 	walkable     []GridPos
+	layout       []string
+	fields       map[GridPos]*DistanceField
+	fieldOrder   []GridPos // cached fields, least recently used first
+	graph        *Graph
+	tiled        *TiledMap
+
+	features      map[GridPos]Feature
+	flipped       map[GridPos]bool
+	tileListeners []func(TileChange)
+	revision      uint64
+
+	playerSpawn    GridPos
+	hasPlayerSpawn bool
+	ghostSpawns    []GridPos
+	fruitSpawns    []GridPos
 }
 
 // DefaultLevel returns the built-in stage used for early development.
@@ -55,7 +128,10 @@ func DefaultLevel() *Level {
 		"#.#.#.###.#.#.#",
 		"#.#.#.....#.#.#",
 		"#.#.#######.#.#",
-		"#.....o.o.....#",
+		// Koro starts where it always has, between the power pellets. The
+		// small pellet it used to eat there on the first frame gave way to the
+		// spawn marker, so clearing the stage takes one pellet fewer.
+		"#.....oPo.....#",
 		"###.###.#.###.#",
 		"#.....# #.....#",
 		"#.###.# #.###.#",
@@ -66,88 +142,212 @@ func DefaultLevel() *Level {
 		"###############",
 	}
 
-	level, err := New(layout, 16)
+	level, err := New(layout, DefaultTileSize)
 	if err != nil {
 		panic(err)
 	}
+	level.ID = DefaultID
 	return level
 }
 
+// ByID rebuilds a stage from the identifier stored in Level.ID.
+func ByID(id string) (*Level, error) {
+	switch {
+	case id == DefaultID:
+		return DefaultLevel(), nil
+	case strings.HasPrefix(id, filePrefix):
+		return LoadFile(strings.TrimPrefix(id, filePrefix))
+	case strings.HasPrefix(id, generatedPrefix):
+		return generated(id)
+	default:
+		return nil, fmt.Errorf("unknown level %q", id)
+	}
+}
+
 // New builds a level from a slice of string rows and the tile size (pixels).
 func New(layout []string, tileSize int) (*Level, error) {
-	if len(layout) == 0 {
-		return nil, fmt.Errorf("empty level layout")
+	l, _, issues := build(layout, tileSize)
+	for _, issue := range issues {
+		if issue.Severity == SeverityError {
+			return nil, issue
+		}
+	}
+	return l, nil
+}
+
+// build parses a layout into a level along with every issue found on the way.
+// The level is only usable when none of the issues is an error.
+func build(layout []string, tileSize int) (*Level, *parsedLayout, []Issue) {
+	grid, meta, metaStart := splitLayout(layout)
+	p, issues := parseLayout(grid)
+	for _, issue := range issues {
+		if issue.Severity == SeverityError {
+			return nil, nil, issues
+		}
+	}
+
+	totalPellets := 0
+	for _, row := range p.pellets {
+		for _, pellet := range row {
+			if pellet != PelletNone {
+				totalPellets++
+			}
+		}
+	}
+
+	l := &Level{
+		Tiles:        p.tiles,
+		TileSize:     tileSize,
+		Width:        p.width,
+		Height:       len(grid),
+		pellets:      p.pellets,
+		modifiers:    p.modifiers,
+		totalPellets: totalPellets,
+		walkable:     p.walkable,
+		layout:       append([]string(nil), layout...),
+		ghostSpawns:  p.ghostSpawns,
+		fruitSpawns:  p.fruitSpawns,
+		features:     p.features,
+		flipped:      map[GridPos]bool{},
 	}
+	if len(p.playerSpawns) > 0 {
+		l.playerSpawn = p.playerSpawns[0]
+		l.hasPlayerSpawn = true
+	}
+	d, directiveIssues := parseDirectives(meta, metaStart)
+	issues = append(issues, directiveIssues...)
+	issues = append(issues, l.linkWarps(p, d)...)
+	issues = append(issues, l.groupFeatures(d)...)
+	l.graph = buildGraph(l)
+	return l, p, issues
+}
+
+// parsedLayout is the raw content of a layout before warps are linked.
+type parsedLayout struct {
+	width        int
+	tiles        [][]TileType
+	pellets      [][]PelletType
+	modifiers    [][]Modifier
+	walkable     []GridPos
+	warps        []GridPos
+	labels       map[rune][]GridPos
+	labelAt      map[GridPos]rune
+	playerSpawns []GridPos
+	ghostSpawns  []GridPos
+	fruitSpawns  []GridPos
+	features     map[GridPos]Feature
+}
 
-	height := len(layout)
-	width := len(layout[0])
-	tiles := make([][]TileType, height)
-	warpEntrances := []GridPos{}
-	walkable := []GridPos{}
-	pellets := make([][]PelletType, height)
+// parseLayout reads every row, collecting all structural problems rather than
+// stopping at the first so the linter can report them together.
+func parseLayout(layout []string) (*parsedLayout, []Issue) {
+	if len(layout) == 0 {
+		return nil, []Issue{levelIssue(SeverityError, "empty", "empty level layout")}
+	}
 
+	p := &parsedLayout{
+		width:     len(layout[0]),
+		tiles:     make([][]TileType, len(layout)),
+		pellets:   make([][]PelletType, len(layout)),
+		modifiers: make([][]Modifier, len(layout)),
+		labels:    map[rune][]GridPos{},
+		labelAt:   map[GridPos]rune{},
+		features:  map[GridPos]Feature{},
+	}
+	var issues []Issue
 	for rowIdx, row := range layout {
-		if len(row) != width {
-			return nil, fmt.Errorf("inconsistent row width at %d", rowIdx)
+		if len(row) != p.width {
+			issues = append(issues, Issue{
+				Row: rowIdx, Col: len(row), Severity: SeverityError, Code: "row-width",
+				Message: fmt.Sprintf("inconsistent row width: got %d, want %d", len(row), p.width),
+			})
 		}
 
-		tiles[rowIdx] = make([]TileType, width)
-		pellets[rowIdx] = make([]PelletType, width)
+		p.tiles[rowIdx] = make([]TileType, p.width)
+		p.pellets[rowIdx] = make([]PelletType, p.width)
+		p.modifiers[rowIdx] = make([]Modifier, p.width)
 		for colIdx, ch := range row {
+			if colIdx >= p.width {
+				break
+			}
+			pos := GridPos{Col: colIdx, Row: rowIdx}
+			tile, pellet, mod := TilePath, PelletNone, Modifier{}
 			switch ch {
 			case '#':
-				tiles[rowIdx][colIdx] = TileWall
-			case '.':
-				tiles[rowIdx][colIdx] = TilePath
-				pellets[rowIdx][colIdx] = PelletSmall
-				walkable = append(walkable, GridPos{Col: colIdx, Row: rowIdx})
-			case 'o':
-				tiles[rowIdx][colIdx] = TilePath
-				pellets[rowIdx][colIdx] = PelletPower
-				walkable = append(walkable, GridPos{Col: colIdx, Row: rowIdx})
+				tile = TileWall
 			case ' ':
-				tiles[rowIdx][colIdx] = TilePath
-				walkable = append(walkable, GridPos{Col: colIdx, Row: rowIdx})
+			case 'P':
+				p.playerSpawns = append(p.playerSpawns, pos)
+			case 'G':
+				p.ghostSpawns = append(p.ghostSpawns, pos)
+			case 'F':
+				p.fruitSpawns = append(p.fruitSpawns, pos)
+			case 'A', 'B', 'C', 'D', 'E':
+				tile = TileWall
+				p.features[pos] = Feature{Kind: FeatureDoor, Key: byte(ch) - 'A' + 'a'}
+			case 'a', 'b', 'c', 'd', 'e':
+				p.features[pos] = Feature{Kind: FeatureKey, Key: byte(ch)}
+			case '+':
+				p.features[pos] = Feature{Kind: FeatureSwitch}
+			case '%':
+				tile = TileWall
+				p.features[pos] = Feature{Kind: FeatureGate}
+			case '_':
+				p.features[pos] = Feature{Kind: FeatureGate, Open: true}
 			case 'W':
-				tiles[rowIdx][colIdx] = TileWarp
-				warpEntrances = append(warpEntrances, GridPos{Col: colIdx, Row: rowIdx})
-				walkable = append(walkable, GridPos{Col: colIdx, Row: rowIdx})
+				tile = TileWarp
+				p.warps = append(p.warps, pos)
+			case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+				tile = TileWarp
+				p.labels[ch] = append(p.labels[ch], pos)
+				p.labelAt[pos] = ch
+			case '=':
+				mod.Surface = SurfaceTunnel
+			case '~':
+				mod.Surface = SurfaceMud
+			case ':':
+				mod.Surface = SurfaceIce
+			case ',':
+				mod.Surface = SurfacePoison
+			case '<':
+				mod = Modifier{Surface: SurfaceConveyor, Flow: GridPos{Col: -1}}
+			case '>':
+				mod = Modifier{Surface: SurfaceConveyor, Flow: GridPos{Col: 1}}
+			case '^':
+				mod = Modifier{Surface: SurfaceConveyor, Flow: GridPos{Row: -1}}
+			case 'v':
+				mod = Modifier{Surface: SurfaceConveyor, Flow: GridPos{Row: 1}}
 			default:
-				return nil, fmt.Errorf("unknown tile rune %q at row %d col %d", ch, rowIdx, colIdx)
+				if pt, ok := pelletRunes[ch]; ok {
+					pellet = pt
+					break
+				}
+				tile = TileWall
+				issues = append(issues, Issue{
+					Row: rowIdx, Col: colIdx, Severity: SeverityError, Code: "unknown-rune",
+					Message: fmt.Sprintf("unknown tile rune %q", ch),
+				})
 			}
-		}
-	}
-
-	warpTargets := map[GridPos]GridPos{}
-	if len(warpEntrances)%2 != 0 {
-		return nil, fmt.Errorf("warp entrances must be even, got %d", len(warpEntrances))
-	}
-	for i := 0; i < len(warpEntrances); i += 2 {
-		a := warpEntrances[i]
-		b := warpEntrances[i+1]
-		warpTargets[a] = b
-		warpTargets[b] = a
-	}
-
-	totalPellets := 0
-	for _, row := range pellets {
-		for _, p := range row {
-			if p != PelletNone {
-				totalPellets++
+			p.tiles[rowIdx][colIdx] = tile
+			p.pellets[rowIdx][colIdx] = pellet
+			if tile == TileWarp {
+				mod.Surface = SurfaceTunnel
+			}
+			p.modifiers[rowIdx][colIdx] = mod
+			if tile != TileWall {
+				p.walkable = append(p.walkable, pos)
 			}
 		}
 	}
 
-	return &Level{
-		Tiles:        tiles,
-		TileSize:     tileSize,
-		Width:        width,
-		Height:       height,
-		warpTargets:  warpTargets,
-		pellets:      pellets,
-		totalPellets: totalPellets,
-		walkable:     walkable,
-	}, nil
+	if len(p.warps)%2 != 0 {
+		last := p.warps[len(p.warps)-1]
+		issues = append(issues, Issue{
+			Row: last.Row, Col: last.Col, Severity: SeverityError, Code: "warp-unpaired",
+			Message: fmt.Sprintf("warp entrances must be even, got %d", len(p.warps)),
+		})
+	}
+	return p, issues
 }
 
 // TileAt returns the tile type at the given column/row.
@@ -177,33 +377,6 @@ func (l *Level) GridForPixel(x, y float64) GridPos {
 	}
 }
 
-// WarpTarget returns the paired warp destination for the provided grid cell.
-func (l *Level) WarpTarget(pos GridPos) (GridPos, bool) {
-	target, ok := l.warpTargets[pos]
-	return target, ok
-}
-
-// Collides reports if a rectangle positioned at (x, y) with the given size is overlapping walls.
-func (l *Level) Collides(x, y, size float64) bool {
-	// Small epsilon reduces floating-point jitter at tile edges.
-	const epsilon = 0.01
-	points := [][2]float64{
-		{x + epsilon, y + epsilon},
-		{x + size - epsilon, y + epsilon},
-		{x + epsilon, y + size - epsilon},
-		{x + size - epsilon, y + size - epsilon},
-	}
-
-	for _, pt := range points {
-		grid := l.GridForPixel(pt[0], pt[1])
-		if l.TileAt(grid.Col, grid.Row) == TileWall {
-			return true
-		}
-	}
-
-	return false
-}
-
 // PelletAt returns the pellet type at the given grid position.
 func (l *Level) PelletAt(col, row int) PelletType {
 	if row < 0 || row >= l.Height || col < 0 || col >= l.Width {
@@ -225,11 +398,85 @@ func (l *Level) ConsumePellet(col, row int) PelletType {
 	return p
 }
 
+// SetPellet places (or with PelletNone, removes) a pellet at the given grid position.
+func (l *Level) SetPellet(col, row int, p PelletType) {
+	if row < 0 || row >= l.Height || col < 0 || col >= l.Width {
+		return
+	}
+	if l.pellets[row][col] != PelletNone {
+		l.totalPellets--
+	}
+	if p != PelletNone {
+		l.totalPellets++
+	}
+	l.pellets[row][col] = p
+}
+
 // RemainingPellets returns the number of pellets left on the map.
 func (l *Level) RemainingPellets() int {
 	return l.totalPellets
 }
 
+// PelletState is a detached copy of what play has changed on a level: the
+// pellets remaining and the state of doors, keys and gates.
+type PelletState struct {
+	pellets [][]PelletType
+	total   int
+	flipped map[GridPos]bool
+}
+
+// SnapshotPellets copies the current pellet layout so it can be restored later.
+func (l *Level) SnapshotPellets() PelletState {
+	return PelletState{
+		pellets: copyPellets(l.pellets),
+		total:   l.totalPellets,
+		flipped: maps.Clone(l.flipped),
+	}
+}
+
+// RestorePellets replaces the pellet layout with a previously taken snapshot.
+func (l *Level) RestorePellets(s PelletState) {
+	if len(s.pellets) != l.Height {
+		return
+	}
+	l.pellets = copyPellets(s.pellets)
+	l.totalPellets = s.total
+	l.setFlipped(s.flipped)
+}
+
+func copyPellets(src [][]PelletType) [][]PelletType {
+	out := make([][]PelletType, len(src))
+	for i, row := range src {
+		out[i] = make([]PelletType, len(row))
+		copy(out[i], row)
+	}
+	return out
+}
+
+// Layout returns the rows the level was built from.
+func (l *Level) Layout() []string {
+	return append([]string(nil), l.layout...)
+}
+
+// PlayerSpawn returns the tile marked 'P', or the first walkable tile when the
+// layout has no marker.
+func (l *Level) PlayerSpawn() GridPos {
+	if l.hasPlayerSpawn || len(l.walkable) == 0 {
+		return l.playerSpawn
+	}
+	return l.walkable[0]
+}
+
+// GhostSpawns returns the tiles marked 'G'; empty means ghosts spawn anywhere.
+func (l *Level) GhostSpawns() []GridPos {
+	return append([]GridPos(nil), l.ghostSpawns...)
+}
+
+// FruitSpawns returns the tiles marked 'F' where bonus fruit appears.
+func (l *Level) FruitSpawns() []GridPos {
+	return append([]GridPos(nil), l.fruitSpawns...)
+}
+
 // WalkableTiles returns a copy of all non-wall tile positions.
 func (l *Level) WalkableTiles() []GridPos {
 	out := make([]GridPos, len(l.walkable))
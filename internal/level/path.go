@@ -0,0 +1,215 @@
+package level
+
+import (
+	"container/heap"
+	"slices"
+)
+
+// Unreachable is the distance reported for tiles with no route to the target.
+const Unreachable = -1
+
+// maxCachedFields bounds the distance field cache. Ghosts chase a handful of
+// tiles at a time, so the least recently used fields are dropped beyond it.
+const maxCachedFields = 32
+
+// DistanceField holds BFS step counts from every tile to one target tile.
+type DistanceField struct {
+	Target GridPos
+	width  int
+	dist   []int
+}
+
+// At returns the number of steps from pos to the field's target, or Unreachable.
+func (d *DistanceField) At(pos GridPos) int {
+	if pos.Col < 0 || pos.Row < 0 || pos.Col >= d.width {
+		return Unreachable
+	}
+	idx := pos.Row*d.width + pos.Col
+	if idx >= len(d.dist) {
+		return Unreachable
+	}
+	return d.dist[idx]
+}
+
+// Walkable reports whether actors can occupy the tile.
+func (l *Level) Walkable(pos GridPos) bool {
+	return l.TileAt(pos.Col, pos.Row) != TileWall
+}
+
+// Neighbors returns the tiles reachable in one step from pos. Stepping onto a
+// warp counts as arriving at its destination, so warp exits are neighbours too.
+func (l *Level) Neighbors(pos GridPos) []GridPos {
+	out := make([]GridPos, 0, 5)
+	for _, d := range [...]GridPos{{0, -1}, {0, 1}, {-1, 0}, {1, 0}} {
+		next := GridPos{Col: pos.Col + d.Col, Row: pos.Row + d.Row}
+		if l.Walkable(next) {
+			out = append(out, next)
+		}
+	}
+	if target, ok := l.WarpTarget(pos); ok {
+		out = append(out, target)
+	}
+	return out
+}
+
+// predecessors returns the tiles that reach pos in one step, the reverse of
+// Neighbors. The two differ only around one-way warps.
+func (l *Level) predecessors(pos GridPos) []GridPos {
+	out := make([]GridPos, 0, 5)
+	for _, d := range [...]GridPos{{0, -1}, {0, 1}, {-1, 0}, {1, 0}} {
+		prev := GridPos{Col: pos.Col + d.Col, Row: pos.Row + d.Row}
+		if l.Walkable(prev) {
+			out = append(out, prev)
+		}
+	}
+	return append(out, l.warpSources[pos]...)
+}
+
+// DistanceField returns the BFS distances to target. The most recently used
+// fields are cached per target tile, so many actors chasing the same tile
+// share one search.
+func (l *Level) DistanceField(target GridPos) *DistanceField {
+	if field, ok := l.fields[target]; ok {
+		l.touchField(target)
+		return field
+	}
+	field := &DistanceField{
+		Target: target,
+		width:  l.Width,
+		dist:   make([]int, l.Width*l.Height),
+	}
+	for i := range field.dist {
+		field.dist[i] = Unreachable
+	}
+	if l.Walkable(target) {
+		field.dist[target.Row*l.Width+target.Col] = 0
+		queue := []GridPos{target}
+		for len(queue) > 0 {
+			cur := queue[0]
+			queue = queue[1:]
+			next := field.dist[cur.Row*l.Width+cur.Col] + 1
+			// Search backwards so one-way warps count towards the target.
+			for _, n := range l.predecessors(cur) {
+				idx := n.Row*l.Width + n.Col
+				if field.dist[idx] == Unreachable {
+					field.dist[idx] = next
+					queue = append(queue, n)
+				}
+			}
+		}
+	}
+	if l.fields == nil {
+		l.fields = map[GridPos]*DistanceField{}
+	}
+	if len(l.fieldOrder) >= maxCachedFields {
+		delete(l.fields, l.fieldOrder[0])
+		l.fieldOrder = l.fieldOrder[1:]
+	}
+	l.fields[target] = field
+	l.fieldOrder = append(l.fieldOrder, target)
+	return field
+}
+
+// touchField marks the cached field for target as the most recently used.
+func (l *Level) touchField(target GridPos) {
+	i := slices.Index(l.fieldOrder, target)
+	l.fieldOrder = append(slices.Delete(l.fieldOrder, i, i+1), target)
+}
+
+// Distance returns the number of steps between two tiles, or Unreachable.
+func (l *Level) Distance(from, to GridPos) int {
+	return l.DistanceField(to).At(from)
+}
+
+// Path returns the tiles of a shortest route from one tile to another using A*,
+// including both endpoints. It returns nil when no route exists.
+func (l *Level) Path(from, to GridPos) []GridPos {
+	if !l.Walkable(from) || !l.Walkable(to) {
+		return nil
+	}
+	open := &pathQueue{}
+	heap.Push(open, pathNode{pos: from, cost: 0, estimate: l.heuristic(from, to)})
+	cameFrom := map[GridPos]GridPos{}
+	best := map[GridPos]int{from: 0}
+
+	for open.Len() > 0 {
+		cur := heap.Pop(open).(pathNode)
+		if cur.pos == to {
+			return reconstructPath(cameFrom, from, to)
+		}
+		if cur.cost > best[cur.pos] {
+			continue
+		}
+		for _, n := range l.Neighbors(cur.pos) {
+			cost := cur.cost + 1
+			if prev, seen := best[n]; seen && prev <= cost {
+				continue
+			}
+			best[n] = cost
+			cameFrom[n] = cur.pos
+			heap.Push(open, pathNode{pos: n, cost: cost, estimate: cost + l.heuristic(n, to)})
+		}
+	}
+	return nil
+}
+
+// heuristic is the Manhattan distance, or the shorter trip through a warp, so
+// A* never overestimates on mazes with tunnels.
+func (l *Level) heuristic(a, b GridPos) int {
+	best := manhattan(a, b)
+	for entrance, w := range l.warps {
+		if via := manhattan(a, entrance) + 1 + manhattan(w.To, b); via < best {
+			best = via
+		}
+	}
+	return best
+}
+
+// invalidatePaths drops cached distance fields and the navigation graph after the maze changes.
+func (l *Level) invalidatePaths() {
+	l.fields = nil
+	l.fieldOrder = nil
+	l.graph = nil
+}
+
+func manhattan(a, b GridPos) int {
+	return abs(a.Col-b.Col) + abs(a.Row-b.Row)
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func reconstructPath(cameFrom map[GridPos]GridPos, from, to GridPos) []GridPos {
+	path := []GridPos{to}
+	for cur := to; cur != from; {
+		cur = cameFrom[cur]
+		path = append(path, cur)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+type pathNode struct {
+	pos      GridPos
+	cost     int
+	estimate int
+}
+
+type pathQueue []pathNode
+
+func (q pathQueue) Len() int           { return len(q) }
+func (q pathQueue) Less(i, j int) bool { return q[i].estimate < q[j].estimate }
+func (q pathQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *pathQueue) Push(x any)        { *q = append(*q, x.(pathNode)) }
+func (q *pathQueue) Pop() any {
+	old := *q
+	n := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return n
+}
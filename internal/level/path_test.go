@@ -0,0 +1,25 @@
+package level
+
+import "testing"
+
+func TestDistanceFieldCacheIsBounded(t *testing.T) {
+	l := DefaultLevel()
+	walkable := l.WalkableTiles()
+	if len(walkable) <= maxCachedFields {
+		t.Fatalf("default level has only %d tiles", len(walkable))
+	}
+	first := l.DistanceField(walkable[0])
+	for _, pos := range walkable[1:] {
+		l.DistanceField(pos)
+		// Keep the first target in use so it survives eviction.
+		if l.DistanceField(walkable[0]) != first {
+			t.Fatal("the most recently used field was evicted")
+		}
+	}
+	if len(l.fields) > maxCachedFields || len(l.fieldOrder) != len(l.fields) {
+		t.Fatalf("cache holds %d fields (order %d), limit %d", len(l.fields), len(l.fieldOrder), maxCachedFields)
+	}
+	if got := l.DistanceField(walkable[1]).At(walkable[1]); got != 0 {
+		t.Fatalf("recomputed field gives %d at its own target", got)
+	}
+}
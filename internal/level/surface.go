@@ -0,0 +1,77 @@
+package level
+
+import "github.com/sky0621/koro/internal/fixed"
+
+// Surface is a movement attribute of a walkable tile.
+type Surface int
+
+const (
+	SurfaceNormal Surface = iota
+	// SurfaceTunnel slows ghosts, as in the arcade warp tunnels. Warp tiles
+	// are always tunnel.
+	SurfaceTunnel
+	// SurfaceMud slows every actor.
+	SurfaceMud
+	// SurfaceIce speeds actors up and keeps them sliding when input stops.
+	SurfaceIce
+	// SurfaceConveyor speeds up movement along its flow, slows movement
+	// against it and carries actors that stand still.
+	SurfaceConveyor
+	// SurfacePoison is a hazard: it does not change speed itself, but the
+	// game slows down a player who crosses it.
+	SurfacePoison
+)
+
+// ActorClass selects which speed table applies to an actor.
+type ActorClass int
+
+const (
+	ActorPlayer ActorClass = iota
+	ActorGhost
+)
+
+// Modifier describes how a tile changes movement across it.
+type Modifier struct {
+	Surface Surface
+	// Flow is the unit step a conveyor pushes towards; zero elsewhere.
+	Flow GridPos
+}
+
+// surfaceSpeeds holds the speed multiplier per surface, indexed by ActorClass.
+var surfaceSpeeds = map[Surface][2]fixed.Num{
+	SurfaceNormal:   {fixed.One, fixed.One},
+	SurfaceTunnel:   {fixed.One, fixed.Half},
+	SurfaceMud:      {fixed.Half, fixed.Half},
+	SurfaceIce:      {fixed.One * 5 / 4, fixed.One * 5 / 4},
+	SurfaceConveyor: {fixed.One, fixed.One},
+	SurfacePoison:   {fixed.One, fixed.One},
+}
+
+const (
+	conveyorWith    = fixed.One * 3 / 2
+	conveyorAgainst = fixed.Half
+)
+
+// ModifierAt returns the movement modifier of the tile at pos.
+func (l *Level) ModifierAt(pos GridPos) Modifier {
+	if pos.Row < 0 || pos.Row >= len(l.modifiers) || pos.Col < 0 || pos.Col >= l.Width {
+		return Modifier{}
+	}
+	return l.modifiers[pos.Row][pos.Col]
+}
+
+// SpeedFactor returns the multiplier for an actor of the given class taking
+// step from the tile at pos. A zero step yields the surface factor alone.
+func (l *Level) SpeedFactor(pos GridPos, class ActorClass, step GridPos) fixed.Num {
+	mod := l.ModifierAt(pos)
+	factor := surfaceSpeeds[mod.Surface][class]
+	if mod.Surface == SurfaceConveyor && step != (GridPos{}) {
+		switch step {
+		case mod.Flow:
+			factor = factor.Mul(conveyorWith)
+		case GridPos{Col: -mod.Flow.Col, Row: -mod.Flow.Row}:
+			factor = factor.Mul(conveyorAgainst)
+		}
+	}
+	return factor
+}
@@ -0,0 +1,699 @@
+package level
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// TiledMap is a maze imported from the Tiled map editor (https://www.mapeditor.org).
+//
+// Tile layers become the layout: a tile's custom properties decide what it is.
+// "rune" names a layout rune outright; otherwise "tile" is one of wall, path,
+// warp, tunnel, mud, ice, poison or conveyor-left/right/up/down and "pellet" is
+// small, power, speed, freeze, magnet, multiplier or shield. Tiles with neither
+// are walls and empty cells are paths, so a map only needs its walls drawn.
+// Later layers paint over earlier ones, except that pellets only land on paths.
+//
+// Objects are placed by class (or type, or name): player, ghost and fruit mark
+// spawns, and warp marks a warp tile. A warp with a "label" digit is paired by
+// label like a digit in a text layout; "to" (a label) makes it one-way and
+// "exit" (left, right, up or down) sets the direction actors leave it.
+type TiledMap struct {
+	Layout                []string
+	Width, Height         int
+	TileWidth, TileHeight int
+	Tilesets              []TiledTileset
+	// Layers holds the global tile IDs of each visible tile layer, row by row,
+	// with the flip flags cleared. Zero means no tile.
+	Layers [][]uint32
+}
+
+// TiledTileset is the image side of a tileset, enough for a renderer to cut tiles.
+type TiledTileset struct {
+	FirstGID              uint32
+	TileCount             int
+	Image                 string // resolved relative to the map file
+	ImageWidth            int
+	ImageHeight           int
+	TileWidth, TileHeight int
+	Columns               int
+	Spacing, Margin       int
+}
+
+// Source returns the tileset tile and its pixel rectangle origin for gid.
+func (t TiledTileset) Source(gid uint32) (x, y int, ok bool) {
+	if gid < t.FirstGID || t.Columns == 0 || int(gid-t.FirstGID) >= t.TileCount {
+		return 0, 0, false
+	}
+	id := int(gid - t.FirstGID)
+	x = t.Margin + (id%t.Columns)*(t.TileWidth+t.Spacing)
+	y = t.Margin + (id/t.Columns)*(t.TileHeight+t.Spacing)
+	return x, y, true
+}
+
+// gidMask strips Tiled's flip and rotation flags from a global tile ID.
+const gidMask = 0x0fffffff
+
+var tiledTileRunes = map[string]byte{
+	"wall":           '#',
+	"path":           ' ',
+	"warp":           'W',
+	"tunnel":         '=',
+	"mud":            '~',
+	"ice":            ':',
+	"conveyor-left":  '<',
+	"conveyor-right": '>',
+	"conveyor-up":    '^',
+	"conveyor-down":  'v',
+	"poison":         ',',
+}
+
+var tiledPelletRunes = map[string]byte{
+	"small":      '.',
+	"power":      'o',
+	"speed":      '!',
+	"freeze":     '*',
+	"magnet":     '?',
+	"multiplier": '$',
+	"shield":     '@',
+}
+
+// IsTiledFile reports whether path names a Tiled map by its extension.
+func IsTiledFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".tmx", ".tmj", ".json":
+		return true
+	}
+	return false
+}
+
+// ImportTiled reads a Tiled map saved as TMX (XML) or JSON, with embedded or
+// external tilesets. Only orthogonal, finite maps are supported.
+func ImportTiled(path string) (*TiledMap, error) {
+	var doc *tiledDoc
+	var err error
+	if strings.EqualFold(filepath.Ext(path), ".tmx") {
+		doc, err = readTMX(path)
+	} else {
+		doc, err = readTMJ(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	m, err := doc.build()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
+
+// LoadTiled builds a level from a Tiled map; its Tiled method returns the map.
+func LoadTiled(path string) (*Level, error) {
+	m, err := ImportTiled(path)
+	if err != nil {
+		return nil, err
+	}
+	// Actor speeds are tuned for the default tile size, so the art is scaled
+	// to fit rather than the level to the art.
+	l, err := New(m.Layout, DefaultTileSize)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	l.ID = filePrefix + path
+	l.tiled = m
+	return l, nil
+}
+
+// Tiled returns the map the level was imported from, or nil for text layouts.
+func (l *Level) Tiled() *TiledMap {
+	return l.tiled
+}
+
+// tiledDoc is the part of a map both file formats decode into.
+type tiledDoc struct {
+	width, height         int
+	tileWidth, tileHeight int
+	orientation           string
+	infinite              bool
+	tilesets              []tiledTilesetDoc
+	layers                [][]uint32
+	objects               []tiledObject
+}
+
+type tiledTilesetDoc struct {
+	TiledTileset
+	props map[uint32]map[string]string
+}
+
+type tiledObject struct {
+	kind  string
+	x, y  float64
+	props map[string]string
+}
+
+func (d *tiledDoc) build() (*TiledMap, error) {
+	switch {
+	case d.orientation != "" && d.orientation != "orthogonal":
+		return nil, fmt.Errorf("unsupported %s orientation", d.orientation)
+	case d.infinite:
+		return nil, fmt.Errorf("infinite maps are not supported")
+	case d.tileWidth <= 0 || d.tileHeight <= 0:
+		return nil, fmt.Errorf("invalid %dx%d tile size", d.tileWidth, d.tileHeight)
+	case d.width <= 0 || d.height <= 0:
+		return nil, fmt.Errorf("empty map")
+	}
+
+	grid := make([][]byte, d.height)
+	for row := range grid {
+		grid[row] = bytes.Repeat([]byte{' '}, d.width)
+	}
+	for _, layer := range d.layers {
+		if len(layer) != d.width*d.height {
+			return nil, fmt.Errorf("tile layer has %d tiles, want %d", len(layer), d.width*d.height)
+		}
+		for i, gid := range layer {
+			if gid == 0 {
+				continue
+			}
+			cell := &grid[i/d.width][i%d.width]
+			ch, pellet, err := d.runeFor(gid)
+			if err != nil {
+				return nil, err
+			}
+			switch {
+			case pellet && *cell == ' ':
+				*cell = ch
+			case !pellet:
+				*cell = ch
+			}
+		}
+	}
+
+	var meta []string
+	for _, obj := range d.objects {
+		col := int(math.Floor(obj.x / float64(d.tileWidth)))
+		row := int(math.Floor(obj.y / float64(d.tileHeight)))
+		if col < 0 || row < 0 || col >= d.width || row >= d.height {
+			return nil, fmt.Errorf("%s object at %.0f,%.0f lies outside the map", obj.kind, obj.x, obj.y)
+		}
+		switch obj.kind {
+		case "player":
+			grid[row][col] = 'P'
+		case "ghost":
+			grid[row][col] = 'G'
+		case "fruit":
+			grid[row][col] = 'F'
+		case "warp":
+			label := obj.props["label"]
+			if label == "" {
+				grid[row][col] = 'W'
+				continue
+			}
+			if _, ok := warpLabel(label); !ok {
+				return nil, fmt.Errorf("warp label %q must be a single digit", label)
+			}
+			grid[row][col] = label[0]
+			if to := obj.props["to"]; to != "" {
+				meta = append(meta, fmt.Sprintf("warp %s -> %s", label, to))
+			}
+			if exit := obj.props["exit"]; exit != "" {
+				meta = append(meta, fmt.Sprintf("exit %s %s", label, exit))
+			}
+		}
+	}
+
+	m := &TiledMap{
+		Width:      d.width,
+		Height:     d.height,
+		TileWidth:  d.tileWidth,
+		TileHeight: d.tileHeight,
+		Layers:     d.layers,
+	}
+	for _, row := range grid {
+		m.Layout = append(m.Layout, string(row))
+	}
+	if len(meta) > 0 {
+		m.Layout = append(append(m.Layout, MetadataSeparator), meta...)
+	}
+	for _, ts := range d.tilesets {
+		m.Tilesets = append(m.Tilesets, ts.TiledTileset)
+	}
+	return m, nil
+}
+
+// runeFor maps a tile to its layout rune; pellet is set for pellet-only tiles.
+func (d *tiledDoc) runeFor(gid uint32) (ch byte, pellet bool, err error) {
+	var props map[string]string
+	for i := len(d.tilesets) - 1; i >= 0; i-- {
+		if ts := d.tilesets[i]; gid >= ts.FirstGID {
+			props = ts.props[gid-ts.FirstGID]
+			break
+		}
+	}
+	if r := props["rune"]; r != "" {
+		if len(r) != 1 {
+			return 0, false, fmt.Errorf("tile %d: rune %q must be one character", gid, r)
+		}
+		_, pellet := pelletRunes[rune(r[0])]
+		return r[0], pellet, nil
+	}
+	if kind := props["tile"]; kind != "" {
+		ch, ok := tiledTileRunes[kind]
+		if !ok {
+			return 0, false, fmt.Errorf("tile %d: unknown tile %q", gid, kind)
+		}
+		if p, ok := tiledPelletRunes[props["pellet"]]; ok && ch == ' ' {
+			return p, false, nil
+		}
+		return ch, false, nil
+	}
+	if kind := props["pellet"]; kind != "" {
+		ch, ok := tiledPelletRunes[kind]
+		if !ok {
+			return 0, false, fmt.Errorf("tile %d: unknown pellet %q", gid, kind)
+		}
+		return ch, true, nil
+	}
+	return '#', false, nil
+}
+
+// decodeTiledData turns a layer's encoded data into global tile IDs.
+func decodeTiledData(encoding, compression, data string) ([]uint32, error) {
+	switch encoding {
+	case "csv":
+		var gids []uint32
+		for _, field := range strings.Split(data, ",") {
+			field = strings.TrimSpace(field)
+			if field == "" {
+				continue
+			}
+			gid, err := strconv.ParseUint(field, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("bad tile id %q", field)
+			}
+			gids = append(gids, uint32(gid)&gidMask)
+		}
+		return gids, nil
+	case "base64":
+		raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(data))
+		if err != nil {
+			return nil, err
+		}
+		var r io.Reader = bytes.NewReader(raw)
+		switch compression {
+		case "":
+		case "zlib":
+			if r, err = zlib.NewReader(r); err != nil {
+				return nil, err
+			}
+		case "gzip":
+			if r, err = gzip.NewReader(r); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unsupported %s compression", compression)
+		}
+		if raw, err = io.ReadAll(r); err != nil {
+			return nil, err
+		}
+		gids := make([]uint32, len(raw)/4)
+		for i := range gids {
+			gids[i] = binary.LittleEndian.Uint32(raw[i*4:]) & gidMask
+		}
+		return gids, nil
+	default:
+		return nil, fmt.Errorf("unsupported %q layer encoding", encoding)
+	}
+}
+
+// objectKind picks the class (Tiled 1.9+), the type (older maps) or the name.
+func objectKind(class, typ, name string) string {
+	for _, k := range [...]string{class, typ, name} {
+		if k != "" {
+			return strings.ToLower(k)
+		}
+	}
+	return ""
+}
+
+// objectCenter returns the point an object marks. Tile objects are anchored
+// at their bottom-left corner, other shapes at their top-left.
+func objectCenter(x, y, width, height float64, gid uint32) (float64, float64) {
+	if gid != 0 {
+		y -= height
+	}
+	return x + width/2, y + height/2
+}
+
+// TMX (XML) format.
+
+type tmxMap struct {
+	Orientation  string           `xml:"orientation,attr"`
+	Width        int              `xml:"width,attr"`
+	Height       int              `xml:"height,attr"`
+	TileWidth    int              `xml:"tilewidth,attr"`
+	TileHeight   int              `xml:"tileheight,attr"`
+	Infinite     int              `xml:"infinite,attr"`
+	Tilesets     []tmxTileset     `xml:"tileset"`
+	Layers       []tmxLayer       `xml:"layer"`
+	ObjectGroups []tmxObjectGroup `xml:"objectgroup"`
+}
+
+type tmxTileset struct {
+	FirstGID   uint32 `xml:"firstgid,attr"`
+	Source     string `xml:"source,attr"`
+	TileWidth  int    `xml:"tilewidth,attr"`
+	TileHeight int    `xml:"tileheight,attr"`
+	TileCount  int    `xml:"tilecount,attr"`
+	Columns    int    `xml:"columns,attr"`
+	Spacing    int    `xml:"spacing,attr"`
+	Margin     int    `xml:"margin,attr"`
+	Image      struct {
+		Source string `xml:"source,attr"`
+		Width  int    `xml:"width,attr"`
+		Height int    `xml:"height,attr"`
+	} `xml:"image"`
+	Tiles []struct {
+		ID         uint32        `xml:"id,attr"`
+		Properties []tmxProperty `xml:"properties>property"`
+	} `xml:"tile"`
+}
+
+type tmxProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type tmxLayer struct {
+	Visible *int `xml:"visible,attr"`
+	Data    struct {
+		Encoding    string `xml:"encoding,attr"`
+		Compression string `xml:"compression,attr"`
+		Text        string `xml:",chardata"`
+	} `xml:"data"`
+}
+
+type tmxObjectGroup struct {
+	Visible *int `xml:"visible,attr"`
+	Objects []struct {
+		Name       string        `xml:"name,attr"`
+		Type       string        `xml:"type,attr"`
+		Class      string        `xml:"class,attr"`
+		X          float64       `xml:"x,attr"`
+		Y          float64       `xml:"y,attr"`
+		Width      float64       `xml:"width,attr"`
+		Height     float64       `xml:"height,attr"`
+		GID        uint32        `xml:"gid,attr"`
+		Properties []tmxProperty `xml:"properties>property"`
+	} `xml:"object"`
+}
+
+func readTMX(path string) (*tiledDoc, error) {
+	var m tmxMap
+	if err := decodeXMLFile(path, &m); err != nil {
+		return nil, err
+	}
+	doc := &tiledDoc{
+		width:       m.Width,
+		height:      m.Height,
+		tileWidth:   m.TileWidth,
+		tileHeight:  m.TileHeight,
+		orientation: m.Orientation,
+		infinite:    m.Infinite != 0,
+	}
+	dir := filepath.Dir(path)
+	for _, ts := range m.Tilesets {
+		converted := ts.convert(ts.FirstGID, dir)
+		if ts.Source != "" {
+			var err error
+			if converted, err = loadTileset(filepath.Join(dir, ts.Source), ts.FirstGID); err != nil {
+				return nil, err
+			}
+		}
+		doc.tilesets = append(doc.tilesets, converted)
+	}
+	for _, layer := range m.Layers {
+		if layer.Visible != nil && *layer.Visible == 0 {
+			continue
+		}
+		encoding := layer.Data.Encoding
+		if encoding == "" {
+			return nil, fmt.Errorf("XML tile data is not supported; save the layer as CSV or Base64")
+		}
+		gids, err := decodeTiledData(encoding, layer.Data.Compression, layer.Data.Text)
+		if err != nil {
+			return nil, err
+		}
+		doc.layers = append(doc.layers, gids)
+	}
+	for _, group := range m.ObjectGroups {
+		if group.Visible != nil && *group.Visible == 0 {
+			continue
+		}
+		for _, obj := range group.Objects {
+			x, y := objectCenter(obj.X, obj.Y, obj.Width, obj.Height, obj.GID)
+			doc.objects = append(doc.objects, tiledObject{
+				kind:  objectKind(obj.Class, obj.Type, obj.Name),
+				x:     x,
+				y:     y,
+				props: tmxProperties(obj.Properties),
+			})
+		}
+	}
+	return doc, nil
+}
+
+func (ts tmxTileset) convert(firstGID uint32, dir string) tiledTilesetDoc {
+	converted := tiledTilesetDoc{
+		TiledTileset: TiledTileset{
+			FirstGID:    firstGID,
+			TileCount:   ts.TileCount,
+			ImageWidth:  ts.Image.Width,
+			ImageHeight: ts.Image.Height,
+			TileWidth:   ts.TileWidth,
+			TileHeight:  ts.TileHeight,
+			Columns:     ts.Columns,
+			Spacing:     ts.Spacing,
+			Margin:      ts.Margin,
+		},
+		props: map[uint32]map[string]string{},
+	}
+	if ts.Image.Source != "" {
+		converted.Image = filepath.Join(dir, ts.Image.Source)
+	}
+	for _, tile := range ts.Tiles {
+		converted.props[tile.ID] = tmxProperties(tile.Properties)
+	}
+	return converted
+}
+
+// loadTileset reads an external tileset in either format; maps of both
+// formats may reference either.
+func loadTileset(path string, firstGID uint32) (tiledTilesetDoc, error) {
+	dir := filepath.Dir(path)
+	if strings.EqualFold(filepath.Ext(path), ".tsx") {
+		var ts tmxTileset
+		if err := decodeXMLFile(path, &ts); err != nil {
+			return tiledTilesetDoc{}, err
+		}
+		return ts.convert(firstGID, dir), nil
+	}
+	var ts tmjTileset
+	if err := decodeJSONFile(path, &ts); err != nil {
+		return tiledTilesetDoc{}, err
+	}
+	return ts.convert(firstGID, dir), nil
+}
+
+func tmxProperties(props []tmxProperty) map[string]string {
+	out := make(map[string]string, len(props))
+	for _, p := range props {
+		out[p.Name] = p.Value
+	}
+	return out
+}
+
+func decodeXMLFile(path string, v any) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return xml.NewDecoder(f).Decode(v)
+}
+
+// JSON (.tmj) format.
+
+type tmjMap struct {
+	Orientation string       `json:"orientation"`
+	Width       int          `json:"width"`
+	Height      int          `json:"height"`
+	TileWidth   int          `json:"tilewidth"`
+	TileHeight  int          `json:"tileheight"`
+	Infinite    bool         `json:"infinite"`
+	Tilesets    []tmjTileset `json:"tilesets"`
+	Layers      []tmjLayer   `json:"layers"`
+}
+
+type tmjTileset struct {
+	FirstGID    uint32 `json:"firstgid"`
+	Source      string `json:"source"`
+	TileWidth   int    `json:"tilewidth"`
+	TileHeight  int    `json:"tileheight"`
+	TileCount   int    `json:"tilecount"`
+	Columns     int    `json:"columns"`
+	Spacing     int    `json:"spacing"`
+	Margin      int    `json:"margin"`
+	Image       string `json:"image"`
+	ImageWidth  int    `json:"imagewidth"`
+	ImageHeight int    `json:"imageheight"`
+	Tiles       []struct {
+		ID         uint32        `json:"id"`
+		Properties []tmjProperty `json:"properties"`
+	} `json:"tiles"`
+}
+
+type tmjProperty struct {
+	Name  string `json:"name"`
+	Value any    `json:"value"`
+}
+
+type tmjLayer struct {
+	Type        string          `json:"type"`
+	Visible     *bool           `json:"visible"`
+	Data        json.RawMessage `json:"data"`
+	Encoding    string          `json:"encoding"`
+	Compression string          `json:"compression"`
+	Objects     []struct {
+		Name       string        `json:"name"`
+		Type       string        `json:"type"`
+		Class      string        `json:"class"`
+		X          float64       `json:"x"`
+		Y          float64       `json:"y"`
+		Width      float64       `json:"width"`
+		Height     float64       `json:"height"`
+		GID        uint32        `json:"gid"`
+		Properties []tmjProperty `json:"properties"`
+	} `json:"objects"`
+}
+
+func readTMJ(path string) (*tiledDoc, error) {
+	var m tmjMap
+	if err := decodeJSONFile(path, &m); err != nil {
+		return nil, err
+	}
+	doc := &tiledDoc{
+		width:       m.Width,
+		height:      m.Height,
+		tileWidth:   m.TileWidth,
+		tileHeight:  m.TileHeight,
+		orientation: m.Orientation,
+		infinite:    m.Infinite,
+	}
+	dir := filepath.Dir(path)
+	for _, ts := range m.Tilesets {
+		converted := ts.convert(ts.FirstGID, dir)
+		if ts.Source != "" {
+			var err error
+			if converted, err = loadTileset(filepath.Join(dir, ts.Source), ts.FirstGID); err != nil {
+				return nil, err
+			}
+		}
+		doc.tilesets = append(doc.tilesets, converted)
+	}
+	for _, layer := range m.Layers {
+		if layer.Visible != nil && !*layer.Visible {
+			continue
+		}
+		switch layer.Type {
+		case "tilelayer":
+			gids, err := layer.gids()
+			if err != nil {
+				return nil, err
+			}
+			doc.layers = append(doc.layers, gids)
+		case "objectgroup":
+			for _, obj := range layer.Objects {
+				x, y := objectCenter(obj.X, obj.Y, obj.Width, obj.Height, obj.GID)
+				doc.objects = append(doc.objects, tiledObject{
+					kind:  objectKind(obj.Class, obj.Type, obj.Name),
+					x:     x,
+					y:     y,
+					props: tmjProperties(obj.Properties),
+				})
+			}
+		}
+	}
+	return doc, nil
+}
+
+// gids decodes a tile layer stored either as a plain array or as base64.
+func (l tmjLayer) gids() ([]uint32, error) {
+	if l.Encoding == "base64" {
+		var data string
+		if err := json.Unmarshal(l.Data, &data); err != nil {
+			return nil, err
+		}
+		return decodeTiledData("base64", l.Compression, data)
+	}
+	var gids []uint32
+	if err := json.Unmarshal(l.Data, &gids); err != nil {
+		return nil, err
+	}
+	for i := range gids {
+		gids[i] &= gidMask
+	}
+	return gids, nil
+}
+
+func (ts tmjTileset) convert(firstGID uint32, dir string) tiledTilesetDoc {
+	converted := tiledTilesetDoc{
+		TiledTileset: TiledTileset{
+			FirstGID:    firstGID,
+			TileCount:   ts.TileCount,
+			ImageWidth:  ts.ImageWidth,
+			ImageHeight: ts.ImageHeight,
+			TileWidth:   ts.TileWidth,
+			TileHeight:  ts.TileHeight,
+			Columns:     ts.Columns,
+			Spacing:     ts.Spacing,
+			Margin:      ts.Margin,
+		},
+		props: map[uint32]map[string]string{},
+	}
+	if ts.Image != "" {
+		converted.Image = filepath.Join(dir, ts.Image)
+	}
+	for _, tile := range ts.Tiles {
+		converted.props[tile.ID] = tmjProperties(tile.Properties)
+	}
+	return converted
+}
+
+func tmjProperties(props []tmjProperty) map[string]string {
+	out := make(map[string]string, len(props))
+	for _, p := range props {
+		out[p.Name] = fmt.Sprint(p.Value)
+	}
+	return out
+}
+
+func decodeJSONFile(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,336 @@
+package level
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// The test maps are 7x3 tiles of 16 pixels. Tile 1 is a wall, 2 a small
+// pellet, 3 a speed pellet given by its rune and 4 a path with a power pellet.
+var tiledTestTiles = []struct {
+	id    int
+	props map[string]string
+}{
+	{0, map[string]string{"tile": "wall"}},
+	{1, map[string]string{"pellet": "small"}},
+	{2, map[string]string{"rune": "!"}},
+	{3, map[string]string{"tile": "path", "pellet": "power"}},
+}
+
+var tiledTestLayers = [][]uint32{
+	{
+		1, 1, 1, 1, 1, 1, 1,
+		1, 2, 0, 0, 3, 4, 1,
+		1, 1, 1, 1, 1, 1, 1,
+	},
+	// Pellets only land on paths, so the speed pellet painted over the
+	// corner wall leaves it a wall.
+	{
+		3, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0,
+	},
+}
+
+// tiledTestLayout is what the test maps import as: the player is a rectangle
+// object and the ghost a tile object anchored at its bottom-left corner.
+var tiledTestLayout = []string{
+	"#######",
+	"#.PG!o#",
+	"#######",
+}
+
+type tiledTestObject struct {
+	class      string
+	x, y, w, h float64
+	gid        uint32
+}
+
+var tiledTestObjects = []tiledTestObject{
+	{class: "player", x: 32, y: 16, w: 16, h: 16},
+	{class: "ghost", x: 48, y: 32, w: 16, h: 16, gid: 1},
+}
+
+// hiddenGhost sits in a hidden object group; importing it would put a
+// ghost on the small pellet.
+var hiddenGhost = tiledTestObject{class: "ghost", x: 16, y: 16, w: 16, h: 16}
+
+func encodeGIDs(t *testing.T, gids []uint32, compression string) string {
+	t.Helper()
+	raw := make([]byte, 0, len(gids)*4)
+	for _, gid := range gids {
+		raw = binary.LittleEndian.AppendUint32(raw, gid)
+	}
+	var buf bytes.Buffer
+	switch compression {
+	case "":
+		buf.Write(raw)
+	case "zlib":
+		w := zlib.NewWriter(&buf)
+		w.Write(raw)
+		w.Close()
+	case "gzip":
+		w := gzip.NewWriter(&buf)
+		w.Write(raw)
+		w.Close()
+	default:
+		t.Fatalf("unknown compression %q", compression)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func writeTestFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// tmxTestMap renders the test map as TMX with the given layer encoding.
+func tmxTestMap(t *testing.T, encoding, compression string) string {
+	t.Helper()
+	var b strings.Builder
+	b.WriteString(`<map orientation="orthogonal" width="7" height="3" tilewidth="16" tileheight="16" infinite="0">`)
+	b.WriteString(`<tileset firstgid="1" tilewidth="16" tileheight="16" tilecount="4" columns="4">`)
+	for _, tile := range tiledTestTiles {
+		fmt.Fprintf(&b, `<tile id="%d"><properties>`, tile.id)
+		for name, value := range tile.props {
+			fmt.Fprintf(&b, `<property name="%s" value="%s"/>`, name, value)
+		}
+		b.WriteString(`</properties></tile>`)
+	}
+	b.WriteString(`</tileset>`)
+	for _, layer := range tiledTestLayers {
+		data := encodeGIDs(t, layer, compression)
+		if encoding == "csv" {
+			var fields []string
+			for _, gid := range layer {
+				fields = append(fields, fmt.Sprint(gid))
+			}
+			data = strings.Join(fields, ",")
+		}
+		fmt.Fprintf(&b, `<layer><data encoding="%s" compression="%s">%s</data></layer>`, encoding, compression, data)
+	}
+	// A hidden layer of walls must not paint over the maze.
+	fmt.Fprintf(&b, `<layer visible="0"><data encoding="csv">%s</data></layer>`, strings.Repeat("1,", 20)+"1")
+	writeObject := func(o tiledTestObject) {
+		fmt.Fprintf(&b, `<object class="%s" x="%g" y="%g" width="%g" height="%g"`, o.class, o.x, o.y, o.w, o.h)
+		if o.gid != 0 {
+			fmt.Fprintf(&b, ` gid="%d"`, o.gid)
+		}
+		b.WriteString(`/>`)
+	}
+	b.WriteString(`<objectgroup>`)
+	for _, o := range tiledTestObjects {
+		writeObject(o)
+	}
+	b.WriteString(`</objectgroup><objectgroup visible="0">`)
+	writeObject(hiddenGhost)
+	b.WriteString(`</objectgroup></map>`)
+	return b.String()
+}
+
+// tmjTestMap returns the test map as TMJ data; encoding "array" stores the
+// tiles as a plain JSON array.
+func tmjTestMap(t *testing.T, encoding, compression string) map[string]any {
+	t.Helper()
+	var tiles []any
+	for _, tile := range tiledTestTiles {
+		var props []any
+		for name, value := range tile.props {
+			props = append(props, map[string]any{"name": name, "type": "string", "value": value})
+		}
+		tiles = append(tiles, map[string]any{"id": tile.id, "properties": props})
+	}
+	var layers []any
+	for _, layer := range tiledTestLayers {
+		l := map[string]any{"type": "tilelayer", "width": 7, "height": 3, "data": layer}
+		if encoding == "base64" {
+			l["data"] = encodeGIDs(t, layer, compression)
+			l["encoding"] = "base64"
+			l["compression"] = compression
+		}
+		layers = append(layers, l)
+	}
+	walls := slices.Repeat([]uint32{1}, 21)
+	layers = append(layers, map[string]any{"type": "tilelayer", "visible": false, "data": walls})
+	object := func(o tiledTestObject) map[string]any {
+		return map[string]any{"class": o.class, "x": o.x, "y": o.y, "width": o.w, "height": o.h, "gid": o.gid}
+	}
+	var objects []any
+	for _, o := range tiledTestObjects {
+		objects = append(objects, object(o))
+	}
+	layers = append(layers,
+		map[string]any{"type": "objectgroup", "objects": objects},
+		map[string]any{"type": "objectgroup", "visible": false, "objects": []any{object(hiddenGhost)}},
+	)
+	return map[string]any{
+		"orientation": "orthogonal",
+		"width":       7,
+		"height":      3,
+		"tilewidth":   16,
+		"tileheight":  16,
+		"infinite":    false,
+		"tilesets": []any{map[string]any{
+			"firstgid": 1, "tilewidth": 16, "tileheight": 16, "tilecount": 4, "columns": 4, "tiles": tiles,
+		}},
+		"layers": layers,
+	}
+}
+
+func marshalTestMap(t *testing.T, m map[string]any) string {
+	t.Helper()
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestImportTiled(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		doc  func(t *testing.T) string
+	}{
+		{"tmx csv", "maze.tmx", func(t *testing.T) string { return tmxTestMap(t, "csv", "") }},
+		{"tmx base64", "maze.tmx", func(t *testing.T) string { return tmxTestMap(t, "base64", "") }},
+		{"tmx base64 zlib", "maze.tmx", func(t *testing.T) string { return tmxTestMap(t, "base64", "zlib") }},
+		{"tmx base64 gzip", "maze.tmx", func(t *testing.T) string { return tmxTestMap(t, "base64", "gzip") }},
+		{"json array", "maze.tmj", func(t *testing.T) string { return marshalTestMap(t, tmjTestMap(t, "array", "")) }},
+		{"json base64", "maze.json", func(t *testing.T) string { return marshalTestMap(t, tmjTestMap(t, "base64", "")) }},
+		{"json base64 zlib", "maze.tmj", func(t *testing.T) string { return marshalTestMap(t, tmjTestMap(t, "base64", "zlib")) }},
+		{"json base64 gzip", "maze.tmj", func(t *testing.T) string { return marshalTestMap(t, tmjTestMap(t, "base64", "gzip")) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTestFile(t, tt.file, tt.doc(t))
+			m, err := ImportTiled(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(m.Layout, tiledTestLayout) {
+				t.Errorf("layout = %q, want %q", m.Layout, tiledTestLayout)
+			}
+			if len(m.Layers) != len(tiledTestLayers) {
+				t.Errorf("got %d layers, want the %d visible ones", len(m.Layers), len(tiledTestLayers))
+			}
+			if _, err := New(m.Layout, DefaultTileSize); err != nil {
+				t.Errorf("imported layout does not load: %v", err)
+			}
+		})
+	}
+}
+
+func TestImportTiledErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(m map[string]any)
+		want   string
+	}{
+		{
+			name: "object left of the map",
+			change: func(m map[string]any) {
+				m["layers"] = append(m["layers"].([]any), map[string]any{
+					"type": "objectgroup", "objects": []any{map[string]any{"class": "ghost", "x": -8, "y": 16}},
+				})
+			},
+			want: "outside the map",
+		},
+		{
+			name: "object past the bottom edge",
+			change: func(m map[string]any) {
+				m["layers"] = append(m["layers"].([]any), map[string]any{
+					"type": "objectgroup", "objects": []any{map[string]any{"class": "ghost", "x": 16, "y": 48}},
+				})
+			},
+			want: "outside the map",
+		},
+		{
+			name: "unknown tile",
+			change: func(m map[string]any) {
+				tiles := m["tilesets"].([]any)[0].(map[string]any)["tiles"].([]any)
+				tiles[0] = map[string]any{"id": 0, "properties": []any{map[string]any{"name": "tile", "value": "lava"}}}
+			},
+			want: `unknown tile "lava"`,
+		},
+		{
+			name: "long rune",
+			change: func(m map[string]any) {
+				tiles := m["tilesets"].([]any)[0].(map[string]any)["tiles"].([]any)
+				tiles[2] = map[string]any{"id": 2, "properties": []any{map[string]any{"name": "rune", "value": "!!"}}}
+			},
+			want: "must be one character",
+		},
+		{
+			name: "short layer",
+			change: func(m map[string]any) {
+				m["layers"].([]any)[0].(map[string]any)["data"] = []uint32{1, 1, 1}
+			},
+			want: "tile layer has 3 tiles, want 21",
+		},
+		{
+			name: "unknown compression",
+			change: func(m map[string]any) {
+				layer := m["layers"].([]any)[0].(map[string]any)
+				layer["encoding"] = "base64"
+				layer["compression"] = "zstd"
+				layer["data"] = "AAAA"
+			},
+			want: "unsupported zstd compression",
+		},
+		{
+			name:   "infinite map",
+			change: func(m map[string]any) { m["infinite"] = true },
+			want:   "infinite maps are not supported",
+		},
+		{
+			name:   "isometric map",
+			change: func(m map[string]any) { m["orientation"] = "isometric" },
+			want:   "unsupported isometric orientation",
+		},
+		{
+			name: "warp label",
+			change: func(m map[string]any) {
+				m["layers"] = append(m["layers"].([]any), map[string]any{
+					"type": "objectgroup", "objects": []any{map[string]any{
+						"class": "warp", "x": 16, "y": 16,
+						"properties": []any{map[string]any{"name": "label", "value": "x"}},
+					}},
+				})
+			},
+			want: `warp label "x" must be a single digit`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := tmjTestMap(t, "array", "")
+			tt.change(m)
+			_, err := ImportTiled(writeTestFile(t, "maze.tmj", marshalTestMap(t, m)))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("error = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestImportTiledXMLData(t *testing.T) {
+	tmx := strings.Replace(tmxTestMap(t, "csv", ""), `encoding="csv"`, "", 1)
+	_, err := ImportTiled(writeTestFile(t, "maze.tmx", tmx))
+	if err == nil || !strings.Contains(err.Error(), "XML tile data is not supported") {
+		t.Fatalf("error = %v, want XML tile data rejected", err)
+	}
+}
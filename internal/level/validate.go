@@ -0,0 +1,184 @@
+package level
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Severity ranks validation issues.
+type Severity string
+
+const (
+	// SeverityError marks layouts that cannot be loaded or played correctly.
+	SeverityError Severity = "error"
+	// SeverityWarning marks layouts that load but are likely unintended.
+	SeverityWarning Severity = "warning"
+)
+
+// Issue is a single validation finding. Row and Col are -1 for problems that
+// concern the whole level rather than one tile.
+type Issue struct {
+	Row      int      `json:"row"`
+	Col      int      `json:"col"`
+	Severity Severity `json:"severity"`
+	Code     string   `json:"code"`
+	Message  string   `json:"message"`
+}
+
+func (i Issue) Error() string {
+	if i.Row < 0 {
+		return i.Message
+	}
+	return fmt.Sprintf("row %d col %d: %s", i.Row, i.Col, i.Message)
+}
+
+func levelIssue(severity Severity, code, message string) Issue {
+	return Issue{Row: -1, Col: -1, Severity: severity, Code: code, Message: message}
+}
+
+// Validate checks a layout for everything New rejects plus problems that only
+// show up in play: missing spawns, open borders, misplaced warps, dead ends and
+// tiles the player can never reach. Issues are ordered by position.
+func Validate(layout []string) []Issue {
+	l, p, issues := build(layout, DefaultTileSize)
+	for _, issue := range issues {
+		if issue.Severity == SeverityError {
+			sortIssues(issues)
+			return issues
+		}
+	}
+
+	switch len(p.playerSpawns) {
+	case 0:
+		issues = append(issues, levelIssue(SeverityError, "no-player-spawn", "missing player spawn marker 'P'"))
+	case 1:
+	default:
+		for _, pos := range p.playerSpawns[1:] {
+			issues = append(issues, tileIssue(pos, SeverityError, "duplicate-player-spawn", "more than one player spawn marker 'P'"))
+		}
+	}
+
+	issues = append(issues, l.featureIssues()...)
+	// Doors and gates can open during play, so the maze checks see them open.
+	l.openFeatures()
+	issues = append(issues, l.borderIssues()...)
+	issues = append(issues, l.warpIssues()...)
+	issues = append(issues, l.deadEndIssues()...)
+	if len(p.playerSpawns) > 0 {
+		issues = append(issues, l.reachabilityIssues(p.playerSpawns[0])...)
+	}
+	sortIssues(issues)
+	return issues
+}
+
+func tileIssue(pos GridPos, severity Severity, code, message string) Issue {
+	return Issue{Row: pos.Row, Col: pos.Col, Severity: severity, Code: code, Message: message}
+}
+
+func (l *Level) onBorder(pos GridPos) bool {
+	return pos.Row == 0 || pos.Col == 0 || pos.Row == l.Height-1 || pos.Col == l.Width-1
+}
+
+// borderIssues flags open edge tiles that would let actors walk off the grid.
+func (l *Level) borderIssues() []Issue {
+	var issues []Issue
+	for _, pos := range l.walkable {
+		if l.onBorder(pos) && l.TileAt(pos.Col, pos.Row) != TileWarp {
+			issues = append(issues, tileIssue(pos, SeverityError, "open-border", "outer border must be wall or warp"))
+		}
+	}
+	return issues
+}
+
+// warpIssues requires warps to sit on the border, paired across facing edges.
+// One-way warps may be used as teleporters, so they only draw warnings.
+func (l *Level) warpIssues() []Issue {
+	var issues []Issue
+	seen := map[GridPos]bool{}
+	for _, pos := range l.walkable {
+		w, ok := l.warps[pos]
+		if !ok || seen[pos] {
+			continue
+		}
+		seen[pos] = true
+		severity := SeverityWarning
+		if !w.OneWay {
+			seen[w.To] = true
+			severity = SeverityError
+		}
+		if !l.onBorder(pos) {
+			issues = append(issues, tileIssue(pos, severity, "warp-off-border", "warp must be on the outer border"))
+		}
+		if !l.onBorder(w.To) {
+			issues = append(issues, tileIssue(w.To, severity, "warp-off-border", "warp must be on the outer border"))
+		}
+		if l.onBorder(pos) && l.onBorder(w.To) && !l.facingEdges(pos, w.To) {
+			issues = append(issues, tileIssue(pos, severity, "warp-not-facing",
+				fmt.Sprintf("warp leads to row %d col %d, which is not on the facing edge", w.To.Row, w.To.Col)))
+		}
+	}
+	return issues
+}
+
+func (l *Level) facingEdges(a, b GridPos) bool {
+	horizontal := (a.Col == 0 && b.Col == l.Width-1) || (b.Col == 0 && a.Col == l.Width-1)
+	vertical := (a.Row == 0 && b.Row == l.Height-1) || (b.Row == 0 && a.Row == l.Height-1)
+	return horizontal || vertical
+}
+
+// deadEndIssues warns about single-exit tiles, where a chased player is trapped.
+func (l *Level) deadEndIssues() []Issue {
+	var issues []Issue
+	g := l.Graph()
+	for _, n := range g.Nodes {
+		if n.Kind == NodeDeadEnd {
+			issues = append(issues, tileIssue(n.Pos, SeverityWarning, "dead-end", "dead end traps the player"))
+		}
+	}
+	return issues
+}
+
+// reachabilityIssues flood-fills from the player spawn and reports every tile it misses.
+func (l *Level) reachabilityIssues(spawn GridPos) []Issue {
+	reached := l.reachableFrom(spawn)
+	var issues []Issue
+	for _, pos := range l.walkable {
+		if reached[pos] {
+			continue
+		}
+		if l.PelletAt(pos.Col, pos.Row) != PelletNone {
+			issues = append(issues, tileIssue(pos, SeverityError, "unreachable-pellet", "pellet cannot be reached from the player spawn"))
+		} else {
+			issues = append(issues, tileIssue(pos, SeverityWarning, "unreachable-tile", "tile cannot be reached from the player spawn"))
+		}
+	}
+	return issues
+}
+
+// reachableFrom returns every tile that can be walked to from start. Unlike
+// DistanceField it searches forwards, so one-way warps count in their
+// direction of travel.
+func (l *Level) reachableFrom(start GridPos) map[GridPos]bool {
+	reached := map[GridPos]bool{start: true}
+	queue := []GridPos{start}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, n := range l.Neighbors(cur) {
+			if !reached[n] {
+				reached[n] = true
+				queue = append(queue, n)
+			}
+		}
+	}
+	return reached
+}
+
+func sortIssues(issues []Issue) {
+	slices.SortStableFunc(issues, func(a, b Issue) int {
+		if a.Row != b.Row {
+			return a.Row - b.Row
+		}
+		return a.Col - b.Col
+	})
+}
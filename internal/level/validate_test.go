@@ -0,0 +1,48 @@
+package level
+
+import "testing"
+
+func TestValidateReachability(t *testing.T) {
+	tests := []struct {
+		name   string
+		layout []string
+		want   []GridPos
+	}{
+		{
+			name:   "one-way warp reaches the far side",
+			layout: []string{"#########", "#P..1#.2#", "#########", "---", "warp 1 -> 2"},
+		},
+		{
+			name:   "one-way warp does not lead back",
+			layout: []string{"#########", "#..2#P.1#", "#########", "---", "warp 1 -> 2"},
+		},
+		{
+			name:   "one-way warp is not walked against its direction",
+			layout: []string{"#########", "#P.2#..1#", "#########", "---", "warp 1 -> 2"},
+			want:   []GridPos{{Col: 5, Row: 1}, {Col: 6, Row: 1}, {Col: 7, Row: 1}},
+		},
+		{
+			name:   "walled-off pellet",
+			layout: []string{"#######", "#P.#..#", "#######"},
+			want:   []GridPos{{Col: 4, Row: 1}, {Col: 5, Row: 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []GridPos
+			for _, issue := range Validate(tt.layout) {
+				if issue.Code == "unreachable-pellet" || issue.Code == "unreachable-tile" {
+					got = append(got, GridPos{Col: issue.Col, Row: issue.Row})
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("unreachable tiles = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("unreachable tiles = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
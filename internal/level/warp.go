@@ -0,0 +1,155 @@
+package level
+
+import (
+	"fmt"
+	"sort"
+)
+
+// MetadataSeparator starts the optional directive section below the grid rows.
+const MetadataSeparator = "---"
+
+// Warp describes where a warp tile leads.
+type Warp struct {
+	To GridPos
+	// Exit is the unit step actors take after arriving; zero keeps their heading.
+	Exit GridPos
+	// OneWay is set when To does not lead back.
+	OneWay bool
+}
+
+var exitSteps = map[string]GridPos{
+	"left":  {Col: -1},
+	"right": {Col: 1},
+	"up":    {Row: -1},
+	"down":  {Row: 1},
+}
+
+// splitLayout separates the grid rows from the directive lines after MetadataSeparator.
+// metaStart is the line index of the first directive.
+func splitLayout(layout []string) (grid, meta []string, metaStart int) {
+	for i, row := range layout {
+		if row == MetadataSeparator {
+			return layout[:i], layout[i+1:], i + 1
+		}
+	}
+	return layout, nil, len(layout)
+}
+
+// linkWarps pairs warp tiles. 'W' tiles pair in scan order (first with second,
+// third with fourth). Digit tiles are labelled: a digit on exactly two tiles
+// forms a two-way pair, while single-tile digits are wired up by the warp and
+// exit directives. Without an exit directive, actors leave a border tile
+// heading inwards.
+func (l *Level) linkWarps(p *parsedLayout, d directives) []Issue {
+	var issues []Issue
+	l.warps = map[GridPos]Warp{}
+
+	for i := 0; i+1 < len(p.warps); i += 2 {
+		a, b := p.warps[i], p.warps[i+1]
+		l.warps[a] = Warp{To: b}
+		l.warps[b] = Warp{To: a}
+	}
+
+	links, exits := d.links, d.exits
+	referenced := map[rune]bool{}
+	for _, lk := range links {
+		referenced[lk.from], referenced[lk.to] = true, true
+	}
+
+	labels := make([]rune, 0, len(p.labels))
+	for label := range p.labels {
+		labels = append(labels, label)
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i] < labels[j] })
+	for _, label := range labels {
+		tiles := p.labels[label]
+		first := tiles[0]
+		switch {
+		case len(tiles) > 2:
+			issues = append(issues, tileIssue(tiles[2], SeverityError, "warp-label-reused",
+				fmt.Sprintf("warp label %c marks %d tiles; use at most two", label, len(tiles))))
+		case len(tiles) == 2 && referenced[label]:
+			issues = append(issues, tileIssue(first, SeverityError, "warp-label-ambiguous",
+				fmt.Sprintf("warp label %c marks two tiles, so directives cannot tell them apart", label)))
+		case len(tiles) == 2:
+			l.warps[tiles[0]] = Warp{To: tiles[1]}
+			l.warps[tiles[1]] = Warp{To: tiles[0]}
+		case !referenced[label]:
+			issues = append(issues, tileIssue(first, SeverityError, "warp-unpaired",
+				fmt.Sprintf("warp label %c has no partner tile or directive", label)))
+		}
+	}
+
+	for _, lk := range links {
+		from, okFrom := p.labels[lk.from]
+		to, okTo := p.labels[lk.to]
+		if !okFrom || !okTo {
+			issues = append(issues, levelIssue(SeverityError, "warp-missing-label",
+				fmt.Sprintf("directive links %c and %c but the grid does not mark both", lk.from, lk.to)))
+			continue
+		}
+		if len(from) != 1 || len(to) != 1 {
+			continue
+		}
+		l.warps[from[0]] = Warp{To: to[0], OneWay: lk.oneWay}
+		if !lk.oneWay {
+			l.warps[to[0]] = Warp{To: from[0]}
+		}
+	}
+
+	for pos, w := range l.warps {
+		if label, ok := p.labelAt[w.To]; ok {
+			if step, ok := exits[label]; ok {
+				w.Exit = step
+			}
+		}
+		if w.Exit == (GridPos{}) {
+			w.Exit = l.inwardStep(w.To)
+		}
+		if back, ok := l.warps[w.To]; !w.OneWay && (!ok || back.To != pos) {
+			w.OneWay = true
+		}
+		l.warps[pos] = w
+	}
+
+	l.warpSources = map[GridPos][]GridPos{}
+	for pos, w := range l.warps {
+		l.warpSources[w.To] = append(l.warpSources[w.To], pos)
+	}
+	return issues
+}
+
+func warpLabel(field string) (rune, bool) {
+	if len(field) != 1 || field[0] < '0' || field[0] > '9' {
+		return 0, false
+	}
+	return rune(field[0]), true
+}
+
+// inwardStep points away from the border edge pos sits on, or is zero off the border.
+func (l *Level) inwardStep(pos GridPos) GridPos {
+	switch {
+	case pos.Col == 0:
+		return GridPos{Col: 1}
+	case pos.Col == l.Width-1:
+		return GridPos{Col: -1}
+	case pos.Row == 0:
+		return GridPos{Row: 1}
+	case pos.Row == l.Height-1:
+		return GridPos{Row: -1}
+	default:
+		return GridPos{}
+	}
+}
+
+// Warp returns where the warp tile at pos leads.
+func (l *Level) Warp(pos GridPos) (Warp, bool) {
+	w, ok := l.warps[pos]
+	return w, ok
+}
+
+// WarpTarget returns the warp destination for the provided grid cell.
+func (l *Level) WarpTarget(pos GridPos) (GridPos, bool) {
+	w, ok := l.warps[pos]
+	return w.To, ok
+}
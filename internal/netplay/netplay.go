@@ -0,0 +1,368 @@
+package netplay
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"sync"
+	"time"
+)
+
+// Role identifies which side of the connection a session is.
+type Role int
+
+const (
+	// RoleHost listens for the peer and picks the match seed.
+	RoleHost Role = iota
+	// RoleGuest dials the host.
+	RoleGuest
+)
+
+// Input is one player's controls for a single simulation frame.
+type Input struct {
+	Dir     uint8
+	Buttons uint8
+}
+
+// Button bits carried in Input.Buttons.
+const (
+	ButtonStart uint8 = 1 << iota
+	ButtonDash
+	ButtonPulse
+)
+
+const (
+	// DefaultInputDelay is how many frames local input is scheduled ahead, hiding link latency.
+	DefaultInputDelay = 3
+	// HashInterval is how often (in frames) peers compare state hashes.
+	HashInterval = 60
+
+	protocolVersion = 2
+	dialTimeout     = 10 * time.Second
+	helloSize       = 18 // plus the level ID
+	messageSize     = 9
+)
+
+var (
+	// ErrDisconnected is reported once the peer leaves or the connection drops.
+	ErrDisconnected = errors.New("netplay: peer disconnected")
+	// ErrDesync is reported when both peers hashed the same frame differently.
+	ErrDesync = errors.New("netplay: simulation desync")
+
+	magic = [4]byte{'K', 'O', 'R', 'O'}
+)
+
+type messageType uint8
+
+const (
+	msgInput messageType = iota + 1
+	msgHash
+	msgBye
+)
+
+// Session is an established connection between two peers.
+type Session struct {
+	conn  net.Conn
+	role  Role
+	seed  int64
+	delay int
+
+	writeMu sync.Mutex
+
+	mu     sync.Mutex
+	inputs [2]map[uint32]Input
+	hashes [2]map[uint32]uint32
+	err    error
+	closed bool
+}
+
+// hello opens a connection in both directions. The guest's seed and delay
+// are unused; both sides check the level ID against their own.
+type hello struct {
+	seed  int64
+	delay int
+	level string
+}
+
+// Host waits for a single peer on addr and sends it the match seed and input
+// delay. The peer must be playing the level with the given ID.
+func Host(addr string, seed int64, delay int, levelID string) (*Session, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listen %s: %w", addr, err)
+	}
+	defer ln.Close()
+	return accept(ln, hello{seed: seed, delay: delay, level: levelID})
+}
+
+func accept(ln net.Listener, local hello) (*Session, error) {
+	conn, err := ln.Accept()
+	if err != nil {
+		return nil, fmt.Errorf("accept: %w", err)
+	}
+	if err := writeHello(conn, local); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	peer, err := readHello(conn)
+	if err == nil {
+		err = checkLevel(peer, local)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return newSession(conn, RoleHost, local.seed, local.delay), nil
+}
+
+// Join connects to a host and adopts its seed and input delay. It fails if
+// the host is playing a different level from levelID.
+func Join(addr string, levelID string) (*Session, error) {
+	conn, err := net.DialTimeout("tcp", addr, dialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("dial %s: %w", addr, err)
+	}
+	host, err := readHello(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	// Answer even on a mismatch, so the host can report it too.
+	local := hello{level: levelID}
+	if err := writeHello(conn, local); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := checkLevel(host, local); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return newSession(conn, RoleGuest, host.seed, host.delay), nil
+}
+
+func checkLevel(peer, local hello) error {
+	if peer.level != local.level {
+		return fmt.Errorf("level mismatch: peer plays %q, local %q", peer.level, local.level)
+	}
+	return nil
+}
+
+func newSession(conn net.Conn, role Role, seed int64, delay int) *Session {
+	s := &Session{
+		conn:  conn,
+		role:  role,
+		seed:  seed,
+		delay: delay,
+	}
+	for i := range s.inputs {
+		s.inputs[i] = map[uint32]Input{}
+		s.hashes[i] = map[uint32]uint32{}
+		// Nobody can have pressed anything during the initial delay window.
+		for f := 0; f < delay; f++ {
+			s.inputs[i][uint32(f)] = Input{}
+		}
+	}
+	go s.readLoop()
+	return s
+}
+
+// Role reports which side of the match this session is.
+func (s *Session) Role() Role {
+	return s.role
+}
+
+// Seed returns the shared seed for the deterministic simulation.
+func (s *Session) Seed() int64 {
+	return s.seed
+}
+
+// InputDelay returns how many frames ahead local input must be scheduled.
+func (s *Session) InputDelay() int {
+	return s.delay
+}
+
+// SendInput records the local input for frame and forwards it to the peer.
+func (s *Session) SendInput(frame uint32, in Input) error {
+	s.mu.Lock()
+	s.inputs[s.role][frame] = in
+	s.mu.Unlock()
+	return s.send(msgInput, frame, uint32(in.Dir)|uint32(in.Buttons)<<8)
+}
+
+// Inputs returns both players' inputs for frame once they are known, ordered host first.
+func (s *Session) Inputs(frame uint32) (host, guest Input, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	host, hostOK := s.inputs[RoleHost][frame]
+	guest, guestOK := s.inputs[RoleGuest][frame]
+	if !hostOK || !guestOK {
+		return Input{}, Input{}, false
+	}
+	delete(s.inputs[RoleHost], frame)
+	delete(s.inputs[RoleGuest], frame)
+	return host, guest, true
+}
+
+func (s *Session) lookup(role Role, frame uint32) (Input, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	in, ok := s.inputs[role][frame]
+	return in, ok
+}
+
+// discard drops buffered inputs for frames before the given one.
+func (s *Session) discard(before uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, inputs := range s.inputs {
+		for f := range inputs {
+			if f < before {
+				delete(inputs, f)
+			}
+		}
+	}
+}
+
+func (s *Session) remoteRole() Role {
+	if s.role == RoleHost {
+		return RoleGuest
+	}
+	return RoleHost
+}
+
+// SendHash shares the local state hash for frame so both sides can detect desyncs.
+func (s *Session) SendHash(frame uint32, hash uint32) error {
+	s.mu.Lock()
+	s.hashes[s.role][frame] = hash
+	s.compareHashesLocked(frame)
+	s.mu.Unlock()
+	return s.send(msgHash, frame, hash)
+}
+
+// Err returns the first failure seen on the session, if any.
+func (s *Session) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close tells the peer we are leaving and releases the connection.
+func (s *Session) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+	_ = s.send(msgBye, 0, 0)
+	return s.conn.Close()
+}
+
+func (s *Session) send(t messageType, frame, value uint32) error {
+	var buf [messageSize]byte
+	buf[0] = byte(t)
+	binary.BigEndian.PutUint32(buf[1:5], frame)
+	binary.BigEndian.PutUint32(buf[5:9], value)
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if _, err := s.conn.Write(buf[:]); err != nil {
+		s.fail(ErrDisconnected)
+		return err
+	}
+	return nil
+}
+
+func (s *Session) readLoop() {
+	remote := s.remoteRole()
+	var buf [messageSize]byte
+	for {
+		if _, err := io.ReadFull(s.conn, buf[:]); err != nil {
+			s.fail(ErrDisconnected)
+			return
+		}
+		frame := binary.BigEndian.Uint32(buf[1:5])
+		value := binary.BigEndian.Uint32(buf[5:9])
+		switch messageType(buf[0]) {
+		case msgInput:
+			s.mu.Lock()
+			s.inputs[remote][frame] = Input{Dir: uint8(value), Buttons: uint8(value >> 8)}
+			s.mu.Unlock()
+		case msgHash:
+			s.mu.Lock()
+			s.hashes[remote][frame] = value
+			s.compareHashesLocked(frame)
+			s.mu.Unlock()
+		case msgBye:
+			s.fail(ErrDisconnected)
+			return
+		}
+	}
+}
+
+func (s *Session) compareHashesLocked(frame uint32) {
+	local, ok := s.hashes[RoleHost][frame]
+	if !ok {
+		return
+	}
+	remote, ok := s.hashes[RoleGuest][frame]
+	if !ok {
+		return
+	}
+	delete(s.hashes[RoleHost], frame)
+	delete(s.hashes[RoleGuest], frame)
+	if local != remote && s.err == nil {
+		s.err = ErrDesync
+	}
+}
+
+func (s *Session) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+func writeHello(w io.Writer, h hello) error {
+	if len(h.level) > math.MaxUint16 {
+		return fmt.Errorf("level ID is %d bytes long", len(h.level))
+	}
+	buf := make([]byte, helloSize, helloSize+len(h.level))
+	copy(buf[0:4], magic[:])
+	binary.BigEndian.PutUint16(buf[4:6], protocolVersion)
+	binary.BigEndian.PutUint16(buf[6:8], uint16(h.delay))
+	binary.BigEndian.PutUint64(buf[8:16], uint64(h.seed))
+	binary.BigEndian.PutUint16(buf[16:18], uint16(len(h.level)))
+	buf = append(buf, h.level...)
+	if _, err := w.Write(buf); err != nil {
+		return fmt.Errorf("send hello: %w", err)
+	}
+	return nil
+}
+
+func readHello(r io.Reader) (hello, error) {
+	var buf [helloSize]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return hello{}, fmt.Errorf("read hello: %w", err)
+	}
+	if [4]byte(buf[0:4]) != magic {
+		return hello{}, fmt.Errorf("peer is not a koro game")
+	}
+	if v := binary.BigEndian.Uint16(buf[4:6]); v != protocolVersion {
+		return hello{}, fmt.Errorf("protocol version mismatch: peer %d, local %d", v, protocolVersion)
+	}
+	level := make([]byte, binary.BigEndian.Uint16(buf[16:18]))
+	if _, err := io.ReadFull(r, level); err != nil {
+		return hello{}, fmt.Errorf("read hello: %w", err)
+	}
+	return hello{
+		seed:  int64(binary.BigEndian.Uint64(buf[8:16])),
+		delay: int(binary.BigEndian.Uint16(buf[6:8])),
+		level: string(level),
+	}, nil
+}
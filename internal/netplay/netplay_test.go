@@ -0,0 +1,128 @@
+package netplay
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// connect pairs a host and a guest over loopback.
+func connect(t *testing.T, host hello, guestLevel string) (*Session, *Session, error, error) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	type result struct {
+		s   *Session
+		err error
+	}
+	hosted := make(chan result, 1)
+	go func() {
+		s, err := accept(ln, host)
+		hosted <- result{s, err}
+	}()
+	guest, guestErr := Join(ln.Addr().String(), guestLevel)
+	h := <-hosted
+	for _, s := range []*Session{h.s, guest} {
+		if s != nil {
+			t.Cleanup(func() { s.Close() })
+		}
+	}
+	return h.s, guest, h.err, guestErr
+}
+
+func mustConnect(t *testing.T, delay int) (*Session, *Session) {
+	t.Helper()
+	host, guest, hostErr, guestErr := connect(t, hello{seed: 42, delay: delay, level: "default"}, "default")
+	if hostErr != nil || guestErr != nil {
+		t.Fatalf("connect: host %v, guest %v", hostErr, guestErr)
+	}
+	return host, guest
+}
+
+// eventually polls cond until it holds or the test times out.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestHandshake(t *testing.T) {
+	host, guest := mustConnect(t, 4)
+	if host.Role() != RoleHost || guest.Role() != RoleGuest {
+		t.Errorf("roles = %v, %v", host.Role(), guest.Role())
+	}
+	if host.Seed() != 42 || guest.Seed() != 42 {
+		t.Errorf("seeds = %d, %d, want 42", host.Seed(), guest.Seed())
+	}
+	if host.InputDelay() != 4 || guest.InputDelay() != 4 {
+		t.Errorf("delays = %d, %d, want 4", host.InputDelay(), guest.InputDelay())
+	}
+}
+
+func TestHandshakeLevelMismatch(t *testing.T) {
+	_, _, hostErr, guestErr := connect(t, hello{seed: 1, delay: 3, level: "default"}, "gen:7")
+	for side, err := range map[string]error{"host": hostErr, "guest": guestErr} {
+		if err == nil || !strings.Contains(err.Error(), "level mismatch") {
+			t.Errorf("%s error = %v, want a level mismatch", side, err)
+		}
+	}
+}
+
+func TestInputsInOrder(t *testing.T) {
+	const delay, frames = 2, 20
+	host, guest := mustConnect(t, delay)
+	for f := uint32(delay); f < frames; f++ {
+		if err := host.SendInput(f, Input{Dir: uint8(f)}); err != nil {
+			t.Fatal(err)
+		}
+		if err := guest.SendInput(f, Input{Dir: uint8(f), Buttons: ButtonDash}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, s := range []*Session{host, guest} {
+		for f := range uint32(frames) {
+			var h, g Input
+			eventually(t, "inputs", func() bool {
+				var ok bool
+				h, g, ok = s.Inputs(f)
+				return ok
+			})
+			want := Input{Dir: uint8(f), Buttons: ButtonDash}
+			if f < delay {
+				want = Input{}
+			}
+			if h.Dir != want.Dir || h.Buttons != 0 || g != want {
+				t.Errorf("role %v frame %d: inputs = %+v, %+v", s.Role(), f, h, g)
+			}
+		}
+	}
+}
+
+func TestDesync(t *testing.T) {
+	host, guest := mustConnect(t, 3)
+	host.SendHash(0, 7)
+	guest.SendHash(0, 7)
+	host.SendHash(HashInterval, 1)
+	guest.SendHash(HashInterval, 2)
+	for _, s := range []*Session{host, guest} {
+		eventually(t, "desync", func() bool { return s.Err() == ErrDesync })
+	}
+}
+
+func TestDisconnect(t *testing.T) {
+	host, guest := mustConnect(t, 3)
+	guest.Close()
+	eventually(t, "disconnect", func() bool { return host.Err() == ErrDisconnected })
+	if err := guest.Close(); err != nil {
+		t.Errorf("closing twice: %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package netplay
+
+const (
+	// DefaultRollbackDelay is the input delay used with rollback; prediction hides the rest.
+	DefaultRollbackDelay = 1
+	// MaxRollbackFrames bounds how far ahead of the peer the simulation may predict.
+	MaxRollbackFrames = 8
+)
+
+// Simulation is the deterministic game state driven by a Rollback session.
+type Simulation interface {
+	// Step advances one frame with both players' inputs.
+	Step(host, guest Input)
+	// Save returns a copy of the whole state.
+	Save() any
+	// Load rewinds to a state returned by Save.
+	Load(state any)
+	// Hash summarises the current state for desync detection.
+	Hash() uint32
+}
+
+// Rollback runs a Simulation ahead of the peer by predicting that the remote
+// player keeps holding their last input. When the real input arrives and
+// differs, it restores the snapshot of that frame and resimulates forward.
+type Rollback struct {
+	session   *Session
+	sim       Simulation
+	frame     uint32
+	nextSend  uint32
+	confirmed uint32
+	predicted map[uint32]Input
+	lastReal  Input
+	snapshots [MaxRollbackFrames + 1]any
+	hashes    map[uint32]uint32
+}
+
+// NewRollback wraps a connected session and the simulation it drives.
+func NewRollback(s *Session, sim Simulation) *Rollback {
+	return &Rollback{
+		session:   s,
+		sim:       sim,
+		nextSend:  uint32(s.InputDelay()),
+		predicted: map[uint32]Input{},
+		hashes:    map[uint32]uint32{},
+	}
+}
+
+// Advance schedules local input, corrects any mispredicted frames and steps the
+// simulation once. It returns false while it is too far ahead of the peer to
+// predict safely and is waiting for their inputs instead.
+func (r *Rollback) Advance(local Input) bool {
+	for r.nextSend <= r.frame+uint32(r.session.InputDelay()) {
+		if err := r.session.SendInput(r.nextSend, local); err != nil {
+			return false
+		}
+		r.nextSend++
+	}
+
+	r.reconcile()
+	if r.frame-r.confirmed >= MaxRollbackFrames {
+		return false
+	}
+	if _, ok := r.session.lookup(r.session.role, r.frame); !ok {
+		return false
+	}
+	r.simulate(r.frame)
+	r.frame++
+	return true
+}
+
+// Confirmed returns the first frame still run on a predicted input. Frames
+// before it will not be simulated again.
+func (r *Rollback) Confirmed() uint32 {
+	return r.confirmed
+}
+
+// NextInputFrame returns the frame the next local input will be sent for.
+// Advance only sends once the simulation has room for it.
+func (r *Rollback) NextInputFrame() uint32 {
+	return r.nextSend
+}
+
+// Frame returns the next frame to be simulated.
+func (r *Rollback) Frame() uint32 {
+	return r.frame
+}
+
+// reconcile confirms frames whose remote input has arrived and rolls back to
+// the first one that was predicted wrongly.
+func (r *Rollback) reconcile() {
+	remote := r.session.remoteRole()
+	rollbackFrom := r.frame
+	for r.confirmed < r.frame {
+		actual, ok := r.session.lookup(remote, r.confirmed)
+		if !ok {
+			break
+		}
+		if actual != r.predicted[r.confirmed] && rollbackFrom == r.frame {
+			rollbackFrom = r.confirmed
+		}
+		delete(r.predicted, r.confirmed)
+		r.lastReal = actual
+		r.confirmed++
+	}
+
+	if rollbackFrom < r.frame {
+		r.sim.Load(r.snapshots[rollbackFrom%uint32(len(r.snapshots))])
+		for f := rollbackFrom; f < r.frame; f++ {
+			r.simulate(f)
+		}
+	}
+
+	r.sendFinalHashes()
+	if r.confirmed > 1 {
+		r.session.discard(r.confirmed - 1)
+	}
+}
+
+// simulate saves the snapshot for frame and steps it with the best inputs known.
+func (r *Rollback) simulate(frame uint32) {
+	r.snapshots[frame%uint32(len(r.snapshots))] = r.sim.Save()
+	if frame%HashInterval == 0 {
+		r.hashes[frame] = r.sim.Hash()
+	}
+
+	local, _ := r.session.lookup(r.session.role, frame)
+	remote, ok := r.session.lookup(r.session.remoteRole(), frame)
+	if !ok {
+		remote = r.lastReal
+	}
+	// Confirmed frames are only resimulated with real inputs, and reconcile
+	// has already stopped tracking them.
+	if frame >= r.confirmed {
+		r.predicted[frame] = remote
+	}
+	if r.session.role == RoleHost {
+		r.sim.Step(local, remote)
+	} else {
+		r.sim.Step(remote, local)
+	}
+}
+
+// sendFinalHashes shares hashes for frames whose start state can no longer change.
+func (r *Rollback) sendFinalHashes() {
+	for frame, hash := range r.hashes {
+		if frame > r.confirmed {
+			continue
+		}
+		delete(r.hashes, frame)
+		if err := r.session.SendHash(frame, hash); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package netplay
+
+import "testing"
+
+// counterSim folds every input into a single number, so any frame stepped
+// with a different input or in a different order ends in a different state.
+type counterSim struct {
+	state uint32
+	loads int
+}
+
+func (s *counterSim) Step(host, guest Input) {
+	s.state = s.state*31 + uint32(host.Dir)*7 + uint32(guest.Dir) + 1
+}
+
+func (s *counterSim) Save() any      { return s.state }
+func (s *counterSim) Load(state any) { s.state = state.(uint32); s.loads++ }
+func (s *counterSim) Hash() uint32   { return s.state }
+
+// waitForInput blocks until s has the remote input for frame.
+func waitForInput(t *testing.T, s *Session, frame uint32) {
+	t.Helper()
+	eventually(t, "remote input", func() bool {
+		_, ok := s.lookup(s.remoteRole(), frame)
+		return ok
+	})
+}
+
+func TestRollbackLateInput(t *testing.T) {
+	host, guest := mustConnect(t, DefaultRollbackDelay)
+	sim := &counterSim{}
+	r := NewRollback(host, sim)
+	hostIn, guestIn := Input{Dir: 1}, Input{Dir: 2}
+
+	// The guest is silent, so the host predicts it holds still.
+	const ahead = 5
+	for range ahead {
+		if !r.Advance(hostIn) {
+			t.Fatal("Advance stalled within the rollback window")
+		}
+	}
+	if sim.loads != 0 {
+		t.Fatalf("rolled back %d times before any input arrived", sim.loads)
+	}
+
+	// The guest's real inputs arrive late and differ from the prediction.
+	for f := uint32(DefaultRollbackDelay); f <= ahead; f++ {
+		if err := guest.SendInput(f, guestIn); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitForInput(t, host, ahead)
+	if !r.Advance(hostIn) {
+		t.Fatal("Advance stalled with every input known")
+	}
+	if sim.loads == 0 {
+		t.Fatal("a mispredicted frame did not roll back")
+	}
+
+	// Lockstep sees the real inputs from the start; the delay window is idle.
+	want := &counterSim{}
+	for f := range r.Frame() {
+		h, g := hostIn, guestIn
+		if f < DefaultRollbackDelay {
+			h, g = Input{}, Input{}
+		}
+		want.Step(h, g)
+	}
+	if sim.state != want.state {
+		t.Errorf("resimulated state = %d, lockstep state = %d", sim.state, want.state)
+	}
+}
+
+func TestRollbackStallsPastWindow(t *testing.T) {
+	host, guest := mustConnect(t, DefaultRollbackDelay)
+	r := NewRollback(host, &counterSim{})
+	for r.Advance(Input{}) {
+		if r.Frame() > 2*MaxRollbackFrames {
+			t.Fatal("Advance never stalled without remote input")
+		}
+	}
+	if ahead := r.Frame() - r.Confirmed(); ahead != MaxRollbackFrames {
+		t.Errorf("stalled %d frames ahead, want %d", ahead, MaxRollbackFrames)
+	}
+	stalled := r.Frame()
+	if r.Advance(Input{}) || r.Frame() != stalled {
+		t.Errorf("Advance moved on from frame %d without remote input", stalled)
+	}
+
+	// One more remote input opens the window by a frame.
+	if err := guest.SendInput(r.Confirmed(), Input{}); err != nil {
+		t.Fatal(err)
+	}
+	waitForInput(t, host, r.Confirmed())
+	if !r.Advance(Input{}) {
+		t.Error("Advance still stalled after the remote input arrived")
+	}
+}
@@ -52,3 +52,8 @@ func FillTriangle(dst *ebiten.Image, ax, ay, bx, by, cx, cy float64, clr color.C
 	drawOpts.ColorScale.ScaleWithColor(clr)
 	vector.FillPath(dst, &path, nil, drawOpts)
 }
+
+// StrokeLine draws a straight line of the given width between two points.
+func StrokeLine(dst *ebiten.Image, x0, y0, x1, y1, width float64, clr color.Color) {
+	vector.StrokeLine(dst, float32(x0), float32(y0), float32(x1), float32(y1), float32(width), clr, false)
+}
@@ -0,0 +1,49 @@
+package rng
+
+import "math/rand"
+
+// Source is a splitmix64 generator whose whole state is a single word, so
+// simulations can snapshot and restore it cheaply.
+type Source struct {
+	state uint64
+}
+
+// NewSource returns a source seeded with seed.
+func NewSource(seed int64) *Source {
+	return &Source{state: uint64(seed)}
+}
+
+// New returns a rand.Rand driven by a fresh Source, along with the source itself.
+func New(seed int64) (*rand.Rand, *Source) {
+	src := NewSource(seed)
+	return rand.New(src), src
+}
+
+// Seed resets the generator.
+func (s *Source) Seed(seed int64) {
+	s.state = uint64(seed)
+}
+
+// Uint64 returns the next pseudo-random value.
+func (s *Source) Uint64() uint64 {
+	s.state += 0x9e3779b97f4a7c15
+	z := s.state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// Int63 returns a non-negative pseudo-random 63-bit integer.
+func (s *Source) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
+
+// State returns the generator state for snapshots.
+func (s *Source) State() uint64 {
+	return s.state
+}
+
+// SetState restores a value previously returned by State.
+func (s *Source) SetState(state uint64) {
+	s.state = state
+}
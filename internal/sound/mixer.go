@@ -0,0 +1,186 @@
+package sound
+
+import (
+	"bytes"
+
+	"github.com/hajimehoshi/ebiten/v2/audio"
+)
+
+// Mixer owns the audio context and plays effects and music at the volumes
+// set on it. Volumes run from 0 to 1; the effect and music channels are both
+// scaled by the master volume, and muting silences everything without
+// forgetting the levels.
+type Mixer struct {
+	ctx     *audio.Context
+	effects [effectCount][]byte
+	wakaAlt []byte
+	tracks  [trackCount][]byte
+
+	master, music, sfx float64
+	muted              bool
+	suspended          bool
+
+	// playing holds the effects still sounding, so they can be re-levelled
+	// when a volume changes.
+	playing  []*audio.Player
+	lastWaka *audio.Player
+	wakaFlip bool
+	track    Track
+	player   *audio.Player
+}
+
+// NewMixer creates the audio context and synthesises every sound. There can
+// only be one per process.
+func NewMixer() *Mixer {
+	m := &Mixer{
+		ctx:    audio.NewContext(SampleRate),
+		master: 1,
+		music:  0.6,
+		sfx:    1,
+	}
+	for e, notes := range effectNotes {
+		m.effects[e] = synthesise(notes...)
+	}
+	m.wakaAlt = synthesise(wakaClose...)
+	for t, notes := range trackNotes {
+		if len(notes) > 0 {
+			m.tracks[t] = synthesise(notes...)
+		}
+	}
+	return m
+}
+
+// Play starts a one-shot effect. A waka is skipped while the previous one is
+// still sounding, so eating a row of pellets chomps steadily.
+func (m *Mixer) Play(e Effect) {
+	pcm := m.effects[e]
+	if e == Waka {
+		if m.lastWaka != nil && m.lastWaka.IsPlaying() {
+			return
+		}
+		if m.wakaFlip {
+			pcm = m.wakaAlt
+		}
+		m.wakaFlip = !m.wakaFlip
+	}
+	p := m.ctx.NewPlayerFromBytes(pcm)
+	p.SetVolume(m.effectVolume())
+	p.Play()
+	if e == Waka {
+		m.lastWaka = p
+	}
+	m.prune()
+	m.playing = append(m.playing, p)
+}
+
+// PlayMusic switches the looping music to t; NoTrack stops it.
+func (m *Mixer) PlayMusic(t Track) {
+	if t == m.track {
+		return
+	}
+	m.track = t
+	if m.player != nil {
+		m.player.Close()
+		m.player = nil
+	}
+	if t == NoTrack {
+		return
+	}
+	pcm := m.tracks[t]
+	p, err := m.ctx.NewPlayer(audio.NewInfiniteLoop(bytes.NewReader(pcm), int64(len(pcm))))
+	if err != nil {
+		return
+	}
+	p.SetVolume(m.musicVolume())
+	m.player = p
+	if !m.suspended {
+		p.Play()
+	}
+}
+
+// Suspend pauses the music, e.g. while the game is paused, and resumes it.
+func (m *Mixer) Suspend(suspended bool) {
+	if suspended == m.suspended {
+		return
+	}
+	m.suspended = suspended
+	if m.player == nil {
+		return
+	}
+	if suspended {
+		m.player.Pause()
+	} else {
+		m.player.Play()
+	}
+}
+
+// SetMaster sets the volume everything is scaled by.
+func (m *Mixer) SetMaster(v float64) {
+	m.master = clamp(v)
+	m.relevel()
+}
+
+// SetMusic sets the music volume.
+func (m *Mixer) SetMusic(v float64) {
+	m.music = clamp(v)
+	m.relevel()
+}
+
+// SetSFX sets the sound effect volume.
+func (m *Mixer) SetSFX(v float64) {
+	m.sfx = clamp(v)
+	m.relevel()
+}
+
+// SetMuted silences or restores all sound.
+func (m *Mixer) SetMuted(muted bool) {
+	m.muted = muted
+	m.relevel()
+}
+
+// Muted reports whether the mixer is muted.
+func (m *Mixer) Muted() bool {
+	return m.muted
+}
+
+func (m *Mixer) effectVolume() float64 {
+	if m.muted {
+		return 0
+	}
+	return m.master * m.sfx
+}
+
+func (m *Mixer) musicVolume() float64 {
+	if m.muted {
+		return 0
+	}
+	return m.master * m.music
+}
+
+func (m *Mixer) relevel() {
+	m.prune()
+	for _, p := range m.playing {
+		p.SetVolume(m.effectVolume())
+	}
+	if m.player != nil {
+		m.player.SetVolume(m.musicVolume())
+	}
+}
+
+// prune drops effects that have finished.
+func (m *Mixer) prune() {
+	live := m.playing[:0]
+	for _, p := range m.playing {
+		if p.IsPlaying() {
+			live = append(live, p)
+		} else {
+			p.Close()
+		}
+	}
+	clear(m.playing[len(live):])
+	m.playing = live
+}
+
+func clamp(v float64) float64 {
+	return min(max(v, 0), 1)
+}
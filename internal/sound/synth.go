@@ -0,0 +1,157 @@
+// Package sound plays the game's sound effects and music. Everything is
+// synthesised from square waves and noise when the mixer starts, in the
+// spirit of the arcade sound chip, so the game ships without audio assets.
+package sound
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// SampleRate is the rate of every synthesised sound and of the audio context.
+const SampleRate = 48000
+
+// amplitude keeps full-volume sounds well clear of clipping when they overlap.
+const amplitude = 0.2
+
+type waveform int
+
+const (
+	square waveform = iota
+	noise
+	silence
+)
+
+// note is one segment of a sound: a wave sweeping linearly from one pitch to
+// another, with a short attack and a linear fade over its last part.
+type note struct {
+	wave     waveform
+	from, to float64 // Hz; for noise, how often a new random level is drawn
+	seconds  float64
+	volume   float64
+	// fade is the fraction of the note spent fading out.
+	fade float64
+}
+
+func tone(from, to, seconds float64) note {
+	return note{wave: square, from: from, to: to, seconds: seconds, volume: 1, fade: 0.3}
+}
+
+func hiss(rate, seconds float64) note {
+	return note{wave: noise, from: rate, to: rate, seconds: seconds, volume: 0.8, fade: 1}
+}
+
+func rest(seconds float64) note {
+	return note{wave: silence, seconds: seconds}
+}
+
+// synthesise renders notes one after another as 16-bit little-endian stereo PCM.
+func synthesise(notes ...note) []byte {
+	var pcm []byte
+	// The noise generator is seeded the same way every time, so each sound
+	// is identical on every run.
+	lfsr := uint16(0xace1)
+	for _, n := range notes {
+		count := int(n.seconds * SampleRate)
+		attack := min(count, SampleRate/500)
+		phase, level := 0.0, 1.0
+		for i := range count {
+			t := float64(i) / float64(count)
+			freq := n.from + (n.to-n.from)*t
+			phase += freq / SampleRate
+			var v float64
+			switch n.wave {
+			case square:
+				v = 1
+				if phase-math.Floor(phase) >= 0.5 {
+					v = -1
+				}
+			case noise:
+				if phase >= 1 {
+					phase -= math.Floor(phase)
+					bit := (lfsr ^ lfsr>>2 ^ lfsr>>3 ^ lfsr>>5) & 1
+					lfsr = lfsr>>1 | bit<<15
+					level = float64(lfsr&1)*2 - 1
+				}
+				v = level
+			}
+			v *= n.volume * amplitude * envelope(i, count, attack, n.fade)
+			s := uint16(int16(v * math.MaxInt16))
+			pcm = binary.LittleEndian.AppendUint16(pcm, s)
+			pcm = binary.LittleEndian.AppendUint16(pcm, s)
+		}
+	}
+	return pcm
+}
+
+// envelope ramps up over the first attack samples and fades out linearly
+// over the last fade fraction of the note, which keeps notes from clicking.
+func envelope(i, count, attack int, fade float64) float64 {
+	gain := 1.0
+	if i < attack {
+		gain = float64(i) / float64(attack)
+	}
+	if fadeLen := int(float64(count) * fade); fadeLen > 0 && i >= count-fadeLen {
+		gain *= float64(count-i) / float64(fadeLen)
+	}
+	return gain
+}
+
+// Effect names a one-shot sound.
+type Effect int
+
+const (
+	// Waka is the chomp of eating a pellet; successive ones alternate halves.
+	Waka Effect = iota
+	Power
+	GhostEaten
+	Death
+	effectCount
+)
+
+// Track names a looping piece of music.
+type Track int
+
+const (
+	NoTrack Track = iota
+	// Siren plays during normal play.
+	Siren
+	// Frightened plays while the ghosts can be eaten.
+	Frightened
+	trackCount
+)
+
+// wakaOpen and wakaClose are the two halves of the chomp.
+var (
+	wakaOpen  = []note{tone(480, 220, 0.07)}
+	wakaClose = []note{tone(220, 480, 0.07)}
+)
+
+var effectNotes = [effectCount][]note{
+	Waka:       wakaOpen,
+	Power:      {tone(200, 800, 0.12), tone(300, 1000, 0.12), tone(400, 1200, 0.16)},
+	GhostEaten: {tone(200, 1600, 0.25)},
+	Death:      deathNotes(),
+}
+
+var trackNotes = [trackCount][]note{
+	Siren:      {quiet(tone(420, 720, 0.4)), quiet(tone(720, 420, 0.4))},
+	Frightened: {quiet(tone(180, 360, 0.12)), quiet(tone(360, 180, 0.12))},
+}
+
+// deathNotes is the arcade's collapsing warble: falling sweeps that each
+// dip in pitch, ending in two pops of noise.
+func deathNotes() []note {
+	var notes []note
+	for f := 900.0; f > 250; f *= 0.85 {
+		notes = append(notes, tone(f, f*0.7, 0.09))
+	}
+	return append(notes, hiss(3000, 0.08), rest(0.05), hiss(3000, 0.08))
+}
+
+// quiet softens a note that plays continuously under the effects.
+func quiet(n note) note {
+	n.volume = 0.35
+	n.fade = 0
+	return n
+}